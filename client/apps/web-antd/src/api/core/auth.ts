@@ -115,39 +115,31 @@ export function seeConnectionClose() {
  * @param companyName 租户/公司名称
  * @param domain 绑定域名(不带http(s)://) 可选
  * @param tenantId 租户id
+ * @param intro 企业简介
  */
 export interface TenantOption {
   company_name: string;
   domain?: string;
   id: string;
+  intro?: string;
 }
 
 /**
  * @param tenantEnabled 是否启用租户
- * @param list 租户列表
+ * @param tenantId 当前域名绑定的租户id 未绑定时为空
+ * @param list 租户列表(域名绑定租户时只包含该租户)
  */
 export interface TenantResp {
   tenantEnabled: boolean;
+  tenantId: string;
   list: TenantOption[];
 }
 
 /**
  * 获取租户列表 下拉框使用
  */
-export async function tenantList() {
-  const tenantEnabled = await pb
-    .collection('global_config')
-    .getFirstListItem("key='tenantEnabled'")
-    .then((item) => item.value as boolean);
-
-  const list = await pb
-    .collection('tenant')
-    .getFullList<TenantOption>({ fields: 'company_name,domain,id' });
-
-  return {
-    tenantEnabled,
-    list,
-  };
+export function tenantList() {
+  return requestClient.get<TenantResp>('/auth/tenant/list');
 }
 
 /**
@@ -6,11 +6,19 @@ import { requestClient } from '#/api/request';
  * @param icon 菜单图标
  * @param noCache 是否不缓存
  * @param link 外链链接
+ * @param query 路由参数(json形式)
+ * @param activeMenu 访问隐藏页面时高亮的菜单路径
+ * @param affix 是否固定在标签栏
+ * @param breadcrumb 是否显示在面包屑
  */
 export interface MenuMeta {
+  activeMenu?: string;
+  affix?: boolean;
+  breadcrumb?: boolean;
   icon: string;
   link?: string;
   noCache: boolean;
+  query?: string;
   title: string;
 }
 
@@ -43,3 +51,12 @@ export interface Menu {
 export async function getAllMenusApi() {
   return requestClient.get<Menu[]>('/system/menu/getRouters');
 }
+
+/**
+ * 获取路由树版本号 版本变化后需重新拉取菜单
+ */
+export async function getRoutersVersionApi() {
+  return requestClient.get<{ version: string }>(
+    '/system/menu/getRouters/version',
+  );
+}
@@ -3,6 +3,7 @@ import type { BaseCollectionModel } from 'pocketbase';
 export interface OperationLog extends BaseCollectionModel {
   id: string;
   tenant_id: string;
+  oper_tenant_id?: string;
   title: string;
   business_type: string;
   business_types?: any;
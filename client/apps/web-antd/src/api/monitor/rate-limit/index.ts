@@ -0,0 +1,15 @@
+import type { RateLimitResp } from './model';
+
+import { requestClient } from '#/api/request';
+
+enum Api {
+  rateLimitList = '/monitor/rateLimit/list',
+}
+
+/**
+ * 限流规则、累计限流次数与最近被限流的请求（仅超级管理员）
+ * @returns 限流信息
+ */
+export function rateLimitList() {
+  return requestClient.get<RateLimitResp>(Api.rateLimitList);
+}
@@ -0,0 +1,22 @@
+export interface RateLimitRule {
+  name: string;
+  method: string;
+  pattern: string;
+  key: 'apikey' | 'ip' | 'tenant' | 'user';
+  window: number;
+  limit: number;
+  limited: number;
+}
+
+export interface RateLimitEvent {
+  rule: string;
+  key: string;
+  method: string;
+  path: string;
+  time: string;
+}
+
+export interface RateLimitResp {
+  rules: RateLimitRule[];
+  recent: RateLimitEvent[];
+}
@@ -3,31 +3,33 @@ import type { Dept } from './model';
 import type { ID, PageQuery } from '#/api/common';
 
 import { buildingQuery } from '#/api/helper';
-import { pb } from '#/api/request';
+import { pb, requestClient } from '#/api/request';
 
 const collection = pb.collection<Dept>('dept');
 
+enum Api {
+  deptExclude = '/system/dept/list/exclude',
+  deptMove = '/system/dept/move',
+}
+
 /**
- * 部门列表（树形结构，获取全部数据）
+ * 部门列表（树形结构，获取全部数据，不含已删除部门）
  * @returns list
  */
 export function deptList(params?: PageQuery) {
   const { filter, sort } = buildingQuery(params);
   return collection.getFullList({
-    filter,
+    filter: filter ? `(${filter}) && del_flag != "1"` : 'del_flag != "1"',
     sort,
   });
 }
 
 /**
- * 查询部门列表（排除节点）
- * 该接口属于特殊端点，保留原始请求方式
+ * 查询可作为上级的部门列表（排除节点自身、下级及已删除部门）
  * @param id 部门ID
  */
 export function deptNodeList(id: ID) {
-  return collection.getFullList({
-    filter: `id != "${id}" && ancestors !~ ",${id}"`,
-  });
+  return requestClient.get<Dept[]>(`${Api.deptExclude}/${id}`);
 }
 
 /**
@@ -56,7 +58,21 @@ export function deptUpdate(data: Partial<Dept>) {
 }
 
 /**
- * 注意这里只有单删除
+ * 移动部门（含全部下级部门）到新的上级部门
+ * @param deptId 部门ID
+ * @param parentId 新的上级部门ID，0 为根部门
+ * @param orderNum 显示顺序
+ */
+export function deptMove(deptId: ID, parentId: ID, orderNum?: number) {
+  return requestClient.putWithMsg<Dept>(Api.deptMove, {
+    dept_id: deptId,
+    order_num: orderNum,
+    parent_id: parentId,
+  });
+}
+
+/**
+ * 注意这里只有单删除；部门为软删除，存在下级部门、用户、岗位或角色数据权限时会被拒绝
  * @param id ID
  */
 export function deptRemove(id: ID) {
@@ -1,15 +1,29 @@
-import type { TenantPackage } from '../tenant-package/model';
-import type { Menu, MenuOption } from './model';
+import type {
+  Menu,
+  MenuDeleteImpact,
+  MenuOption,
+  MenuRecycle,
+  MenuScaffold,
+  MenuScaffoldResult,
+  MenuSeedReport,
+} from './model';
 
 import type { ID, IDS, PageQuery } from '#/api/common';
 
-import { buildingQuery } from '#/api/helper';
-import { Ors, pb } from '#/api/request';
-import { buildTree } from '#/utils/tree';
+import { buildingQuery, ContentTypeEnum } from '#/api/helper';
+import { Ors, pb, requestClient } from '#/api/request';
 
 const menuCollection = pb.collection<Menu>('menu');
-const roleMenuCollection = pb.collection('role_menu');
-const tenantPackageCollection = pb.collection('tenant_package');
+
+enum Api {
+  deleteImpact = '/system/menu/deleteImpact',
+  recycle = '/system/menu/recycle',
+  roleMenuTreeselect = '/system/menu/roleMenuTreeselect',
+  scaffold = '/system/menu/scaffold',
+  seed = '/system/menu/seed',
+  tenantPackageMenuTreeselect = '/system/menu/tenantPackageMenuTreeselect',
+  treeselect = '/system/menu/treeselect',
+}
 
 /**
  * 菜单列表（树形结构，获取全部数据）
@@ -62,9 +76,48 @@ export function menuUpdate(data: Partial<Menu>) {
 /**
  * 菜单删除
  * @param menuIds ids
+ * @param cascade 级联删除下级菜单及角色、套餐关联（移入回收站）
+ */
+export function menuRemove(menuIds: IDS, cascade = false) {
+  const query = cascade ? { cascade: 'true' } : undefined;
+  return Promise.all(
+    menuIds.map((id) => menuCollection.delete(`${id}`, { query })),
+  );
+}
+
+/**
+ * 删除菜单的影响范围（下级菜单、已分配角色、租户套餐）
+ * @param menuId 菜单id
+ */
+export function menuDeleteImpact(menuId: ID) {
+  return requestClient.get<MenuDeleteImpact>(`${Api.deleteImpact}/${menuId}`);
+}
+
+/**
+ * 菜单回收站列表
  */
-export function menuRemove(menuIds: IDS) {
-  return Promise.all(menuIds.map((id) => menuCollection.delete(`${id}`)));
+export function menuRecycleList() {
+  return requestClient.get<MenuRecycle[]>(`${Api.recycle}/list`);
+}
+
+/**
+ * 从回收站恢复菜单
+ * @param id 回收站记录id
+ */
+export function menuRecycleRestore(id: ID) {
+  return requestClient.postWithMsg<{
+    menus: number;
+    packages: number;
+    roles: number;
+  }>(`${Api.recycle}/${id}/restore`);
+}
+
+/**
+ * 彻底删除回收站记录
+ * @param id 回收站记录id
+ */
+export function menuRecyclePurge(id: ID) {
+  return requestClient.deleteWithMsg<void>(`${Api.recycle}/${id}`);
 }
 
 /**
@@ -72,71 +125,70 @@ export function menuRemove(menuIds: IDS) {
  * @param roleId id
  * @returns resp
  */
-export async function roleMenuTreeSelect(roleId: ID) {
-  // 1) 查询当前角色已勾选的菜单ID
-  const roleMenus = await roleMenuCollection.getFullList<{
-    menu: string;
-  }>({
-    filter: `role="${roleId}"`,
-    fields: 'menu',
-  });
-  const checkedKeys = roleMenus.map((m) => m.menu);
+export function roleMenuTreeSelect(roleId: ID) {
+  return requestClient.get<{ checkedKeys: string[]; menus: MenuOption[] }>(
+    `${Api.roleMenuTreeselect}/${roleId}`,
+  );
+}
 
-  const menus = await menuTreeSelect();
+/**
+ * 下拉框使用  返回当前用户可分配的菜单
+ * @returns []
+ */
+export function menuTreeSelect() {
+  return requestClient.get<MenuOption[]>(Api.treeselect);
+}
 
-  return { checkedKeys, menus };
+/**
+ * 租户套餐使用
+ * @param packageId packageId
+ * @returns resp
+ */
+export function tenantPackageMenuTreeSelect(packageId: ID) {
+  return requestClient.get<{ checkedKeys: string[]; menus: MenuOption[] }>(
+    `${Api.tenantPackageMenuTreeselect}/${packageId}`,
+  );
 }
 
 /**
- * 下拉框使用  返回所有的菜单
- * @returns []
+ * 导出菜单种子（JSON 菜单树）
+ * @returns blob
  */
-export async function menuTreeSelect(filter: string = `status="0"`) {
-  const menus = await menuCollection.getFullList<{
-    icon?: string;
-    id: string;
-    menu_name: string;
-    menu_type: string;
-    order_num?: number;
-    parent_id?: null | string;
-    status: string;
-  }>({
-    filter,
-    fields: 'id,parent_id,menu_name,menu_type,icon,order_num,status',
+export function menuSeedExport() {
+  return requestClient.get<Blob>(Api.seed, {
+    isTransformResponse: false,
+    responseType: 'blob',
   });
+}
 
-  const roots = buildTree<any, MenuOption>(menus, {
-    getId: (m) => m.id,
-    getParentId: (m) => m.parent_id ?? '0',
-    rootPid: '0',
-    getLabel: (m) => m.menu_name,
-    getWeight: (m) => m.order_num ?? 0,
-    assign: (m, node: any) => {
-      node.menuType = m.menu_type;
-      node.icon = m.icon ?? '#';
-      node.key = m.id;
+/**
+ * 导入菜单种子（按 key 幂等更新）
+ * @param file JSON 文件
+ * @param options dryRun 只返回差异；prune 删除种子中不存在的菜单
+ * @returns 差异报告
+ */
+export function menuSeedImport(
+  file: Blob,
+  options?: { dryRun?: boolean; prune?: boolean },
+) {
+  return requestClient.post<MenuSeedReport>(
+    Api.seed,
+    { file },
+    {
+      headers: { 'Content-Type': ContentTypeEnum.FORM_DATA },
+      params: {
+        dryRun: options?.dryRun ? 'true' : undefined,
+        prune: options?.prune ? 'true' : undefined,
+      },
     },
-  });
-
-  return roots as unknown as MenuOption[];
+  );
 }
 
 /**
- * 租户套餐使用
- * @param packageId packageId
- * @returns resp
+ * 为集合一键生成页面菜单与按钮权限（可同时授权给角色、租户套餐）
+ * @param data 参数
+ * @returns 生成结果
  */
-export async function tenantPackageMenuTreeSelect(packageId: ID) {
-  const checkedKeys =
-    `${packageId}` === '0'
-      ? []
-      : await tenantPackageCollection
-          .getOne<TenantPackage>(`${packageId}`)
-          .then((tp) => {
-            return tp.menu_ids;
-          });
-
-  const menus = await menuTreeSelect(`status="0"&&menu_name!~"租户"`);
-
-  return { checkedKeys, menus };
+export function menuScaffold(data: MenuScaffold) {
+  return requestClient.postWithMsg<MenuScaffoldResult>(Api.scaffold, data);
 }
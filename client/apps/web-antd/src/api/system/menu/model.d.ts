@@ -8,6 +8,8 @@ export interface Menu extends BaseCollectionModel {
   remark?: any;
   id: string;
   menu_name: string;
+  /** 各语言的菜单名称, 如 { 'en-US': 'System' } */
+  menu_name_i18n?: Record<string, string>;
   parent_name?: string;
   parent_id: string;
   order_num: number;
@@ -29,13 +31,13 @@ export interface Menu extends BaseCollectionModel {
  * @param label 菜单名称
  */
 export interface MenuOption {
-  id: number;
-  parent_id: string;
+  id: string;
+  parentId: string;
   label: string;
   weight: number;
   children: MenuOption[];
-  key: string; // 实际上不存在 ide报错
-  menu_type: string;
+  key: string;
+  menuType: string;
   icon: string;
 }
 
@@ -57,3 +59,82 @@ export interface MenuQuery {
   visible?: string;
   status?: string;
 }
+
+/**
+ * 菜单种子导入差异
+ */
+export interface MenuSeedChange {
+  action: 'add' | 'change' | 'remove';
+  key: string;
+  id: string;
+  menu_name: string;
+  fields?: Record<string, { from: any; to: any }>;
+}
+
+/**
+ * 菜单种子导入结果
+ */
+export interface MenuSeedReport {
+  dry_run: boolean;
+  prune: boolean;
+  added: number;
+  changed: number;
+  removed: number;
+  changes: MenuSeedChange[];
+}
+
+export interface MenuRef {
+  id: string;
+  name: string;
+}
+
+/**
+ * 删除菜单的影响范围
+ */
+export interface MenuDeleteImpact {
+  menu_id: string;
+  menu_name: string;
+  children: MenuRef[];
+  roles: MenuRef[];
+  packages: MenuRef[];
+}
+
+/**
+ * 菜单回收站记录
+ */
+export interface MenuRecycle {
+  id: string;
+  menu_id: string;
+  menu_name: string;
+  item_count: number;
+  delete_by: string;
+  create_time: string;
+}
+
+/**
+ * 集合菜单生成参数，未填写的字段按集合名称生成
+ */
+export interface MenuScaffold {
+  collection: string;
+  parent_id?: string;
+  menu_name?: string;
+  path?: string;
+  component?: string;
+  icon?: string;
+  order_num?: number;
+  /** query/add/edit/remove/export/import，为空时全部生成 */
+  actions?: string[];
+  role_ids?: string[];
+  package_ids?: string[];
+}
+
+/**
+ * 集合菜单生成结果
+ */
+export interface MenuScaffoldResult {
+  menu_id: string;
+  created: MenuRef[];
+  existing: MenuRef[];
+  roles: number;
+  packages: number;
+}
@@ -5,6 +5,8 @@ export interface Notice extends BaseCollectionModel {
   notice_title: string;
   notice_type: string;
   notice_content: string;
+  /** 接收人（用户ID），为空时为租户内公告 */
+  receiver_id?: string;
   status: string;
   remark: string;
   create_by: number;
@@ -43,6 +43,8 @@ export interface User extends BaseCollectionModel {
   status: string;
   login_ip: string;
   login_date: string;
+  /** 语言偏好(菜单名称等服务端文案), 为空时跟随界面语言 */
+  language?: string;
   remark: string;
   create_time: string;
   dept: Dept;
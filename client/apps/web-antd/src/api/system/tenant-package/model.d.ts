@@ -7,6 +7,8 @@ import type { BaseCollectionModel } from 'pocketbase';
  * @param menu_ids 菜单id  格式为[1,2,3] 返回为string 提交为数组
  * @param remark 备注
  * @param menu_check_strictly 是否关联父节点
+ * @param api_call_limit 每月接口调用上限 0为不限制
+ * @param storage_limit_mb 存储空间上限(MB) 0为不限制
  * @param status 状态
  */
 export interface TenantPackage extends BaseCollectionModel {
@@ -15,5 +17,7 @@ export interface TenantPackage extends BaseCollectionModel {
   menu_ids: string[];
   remark?: string;
   menu_check_strictly?: boolean;
+  api_call_limit?: number;
+  storage_limit_mb?: number;
   status?: string;
 }
@@ -1,4 +1,4 @@
-import type { Tenant } from './model';
+import type { Tenant, TenantUsageResp } from './model';
 
 import type { ID, IDS, PageQuery } from '#/api/common';
 
@@ -16,6 +16,7 @@ enum Api {
   tenantList = '/system/tenant/list',
   tenantStatus = '/system/tenant/changeStatus',
   tenantSyncPackage = '/system/tenant/syncTenantPackage',
+  tenantUsage = '/system/tenant/usage',
 }
 
 /**
@@ -129,3 +130,12 @@ export function dictSyncTenant(tenantId?: string) {
     successMessageMode: 'message',
   });
 }
+
+/**
+ * 查询租户用量（当前用量、套餐限制与月度历史）
+ * @param tenantId 租户ID
+ * @returns 用量信息
+ */
+export function tenantUsage(tenantId: string) {
+  return requestClient.get<TenantUsageResp>(`${Api.tenantUsage}/${tenantId}`);
+}
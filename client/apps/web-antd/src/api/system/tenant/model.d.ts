@@ -15,3 +15,22 @@ export interface Tenant extends BaseCollectionModel {
   status: string;
   package_id: string;
 }
+
+export interface TenantUsage {
+  tenant_id: string;
+  month: string;
+  api_calls: number;
+  storage_bytes: number;
+  user_count: number;
+  record_counts: Record<string, number>;
+}
+
+export interface TenantUsageResp {
+  current: TenantUsage;
+  limits: {
+    account_count: number;
+    api_call_limit: number;
+    storage_limit_mb: number;
+  };
+  history: TenantUsage[];
+}
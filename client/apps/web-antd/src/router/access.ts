@@ -122,6 +122,9 @@ function backMenuToVbenMenu(
         // 当前路由不在菜单显示 但是可以通过链接访问
         // 不可访问的路由由后端控制隐藏(不返回对应路由)
         hideInMenu: menu.hidden,
+        activePath: menu.meta?.activeMenu || undefined,
+        affixTab: menu.meta?.affix,
+        hideInBreadcrumb: menu.meta?.breadcrumb === false,
         icon: menu.meta?.icon,
         keepAlive: !menu.meta?.noCache,
         title: menu.meta?.title,
@@ -142,9 +145,10 @@ function backMenuToVbenMenu(
     }
 
     // 添加路由参数信息
-    if (menu.query) {
+    const menuQuery = menu.meta?.query || menu.query;
+    if (menuQuery) {
       try {
-        const query = JSON.parse(menu.query);
+        const query = JSON.parse(menuQuery);
         vbenRoute.meta && (vbenRoute.meta.query = query);
       } catch {
         console.error('错误的路由参数类型, 必须为[json]格式');
@@ -221,6 +221,12 @@ export const drawerSchema: FormSchemaGetter = () => [
     help: '支持i18n写法, 如: menu.system.user',
     rules: 'required',
   },
+  {
+    component: 'Input',
+    fieldName: 'menu_name_en',
+    label: '英文名称',
+    help: '英文界面(en-US)显示的菜单名称, 为空时使用菜单名称',
+  },
   {
     component: 'InputNumber',
     fieldName: 'order_num',
@@ -389,6 +395,51 @@ export const drawerSchema: FormSchemaGetter = () => [
     help: '路由的keepAlive属性',
     label: '是否缓存',
   },
+  {
+    component: 'Input',
+    dependencies: {
+      // 类型为菜单时显示
+      show: (values) => values.menu_type === 'C',
+      triggerFields: ['menu_type'],
+    },
+    fieldName: 'active_menu',
+    help: '隐藏的详情页访问时高亮的菜单路径\n 如: /system/user',
+    label: '高亮菜单',
+  },
+  {
+    component: 'RadioGroup',
+    componentProps: {
+      buttonStyle: 'solid',
+      options: yesNoOptions,
+      optionType: 'button',
+    },
+    defaultValue: '1',
+    dependencies: {
+      // 类型为菜单时显示
+      show: (values) => values.menu_type === 'C',
+      triggerFields: ['menu_type'],
+    },
+    fieldName: 'affix',
+    help: '固定在标签栏, 不可关闭',
+    label: '固定标签',
+  },
+  {
+    component: 'RadioGroup',
+    componentProps: {
+      buttonStyle: 'solid',
+      options: yesNoOptions,
+      optionType: 'button',
+    },
+    defaultValue: '0',
+    dependencies: {
+      // 类型不为按钮时显示
+      show: (values) => values.menu_type !== 'F',
+      triggerFields: ['menu_type'],
+    },
+    fieldName: 'breadcrumb',
+    help: '是否显示在面包屑中',
+    label: '显示面包屑',
+  },
   {
     component: 'Input',
     fieldName: 'remark',
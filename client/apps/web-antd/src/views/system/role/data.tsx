@@ -19,6 +19,8 @@ export const authScopeOptions = [
   { color: 'cyan', label: '本部门及以下数据权限', value: '4' },
   { color: 'error', label: '仅本人数据权限', value: '5' },
   { color: 'default', label: '部门及以下或本人数据权限', value: '6' },
+  { color: 'purple', label: '本人及所领导部门成员数据权限', value: '7' },
+  { color: 'blue', label: '本人及同岗位成员数据权限', value: '8' },
 ];
 
 export const querySchema: FormSchemaGetter = () => [
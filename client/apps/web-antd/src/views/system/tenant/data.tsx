@@ -48,6 +48,17 @@ export const columns: VxeGridProps['columns'] = [
     title: '联系电话',
     field: 'contact_phone',
   },
+  {
+    title: '用户数量',
+    field: 'account_count',
+    formatter: ({ cellValue, row }) => {
+      const used = row.account_used ?? 0;
+      if (cellValue === -1 || !cellValue) {
+        return `${used} / 不限制`;
+      }
+      return `${used} / ${cellValue}`;
+    },
+  },
   {
     title: '到期时间',
     field: 'expire_time',
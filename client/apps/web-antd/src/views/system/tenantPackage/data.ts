@@ -63,6 +63,28 @@ export const drawerSchema: FormSchemaGetter = () => [
     fieldName: 'menu_ids',
     label: '关联菜单',
   },
+  {
+    component: 'InputNumber',
+    componentProps: {
+      min: 0,
+      placeholder: '0为不限制',
+    },
+    defaultValue: 0,
+    fieldName: 'api_call_limit',
+    help: '租户每月接口调用次数上限，超出后接口返回 429',
+    label: '调用上限/月',
+  },
+  {
+    component: 'InputNumber',
+    componentProps: {
+      min: 0,
+      placeholder: '0为不限制',
+    },
+    defaultValue: 0,
+    fieldName: 'storage_limit_mb',
+    help: '租户文件存储空间上限(MB)，超出后上传返回 507',
+    label: '存储上限(MB)',
+  },
   {
     component: 'Textarea',
     fieldName: 'remark',
@@ -2,14 +2,11 @@ package auth
 
 import (
 	// Use simple line parsing to avoid introducing extra dependencies
-	"fmt"
 	"os"
 	"path/filepath"
-	"pocketbase-ruoyi/api/system"
 	"pocketbase-ruoyi/tools"
 	"strings"
 
-	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 )
@@ -27,7 +24,7 @@ func RegisterDataScope(app *pocketbase.PocketBase) {
 			return e.Next()
 		}
 
-		tenantIDField := e.Collection.Fields.GetByName("tenant_id")
+		fields := tools.GetDataScopeFields(e.Collection)
 		createDeptField := e.Collection.Fields.GetByName("create_dept")
 		createByField := e.Collection.Fields.GetByName("create_by")
 
@@ -37,9 +34,7 @@ func RegisterDataScope(app *pocketbase.PocketBase) {
 		userTenantID := tools.GetUserTenant(e.RequestEvent)
 		userDeptID := e.Auth.GetString("dept_id")
 
-		if tenantIDField != nil {
-			e.Record.Set("tenant_id", userTenantID)
-		}
+		fields.Tenant.Assign(e.Record, userTenantID)
 
 		if createDeptField != nil {
 			e.Record.Set("create_dept", userDeptID)
@@ -49,6 +44,13 @@ func RegisterDataScope(app *pocketbase.PocketBase) {
 			e.Record.Set("create_by", e.Auth.Id)
 		}
 
+		// Mapped dept/owner fields (e.g. dept_id, owner) default to the current user
+		// but keep any value chosen by the client.
+		if userDeptID != "" {
+			fields.Dept.AssignIfEmpty(e.Record, userDeptID)
+		}
+		fields.Owner.AssignIfEmpty(e.Record, e.Auth.Id)
+
 		if updateDeptField != nil {
 			e.Record.Set("update_dept", userDeptID)
 		}
@@ -84,7 +86,11 @@ func RegisterDataScope(app *pocketbase.PocketBase) {
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 		se.Router.BindFunc(func(e *core.RequestEvent) error {
 
-			if e.Auth == nil || e.Auth.IsSuperuser() {
+			// Anonymous requests are only scoped when the domain resolves to a tenant.
+			if e.Auth == nil && tools.GetDomainTenant(e) == "" {
+				return e.Next()
+			}
+			if e.Auth != nil && e.Auth.IsSuperuser() {
 				return e.Next()
 			}
 
@@ -100,83 +106,57 @@ func RegisterDataScope(app *pocketbase.PocketBase) {
 				return e.Next()
 			}
 
-			collectionName = collection.Name
-
-			// If the collection is in the data_scope whitelist, skip appending the data_scope filter
-			if collectionName != "" {
-				if _, ok := collWhitelist[collectionName]; ok {
-					return e.Next()
-				}
+			// If the collection is in the data_scope whitelist, skip the data_scope restriction
+			if _, ok := collWhitelist[collection.Name]; ok {
+				return e.Next()
 			}
 
-			tenantIDField := collection.Fields.GetByName("tenant_id")
-			createDeptField := collection.Fields.GetByName("create_dept")
-			createByField := collection.Fields.GetByName("create_by")
-
-			userTenantID := tools.GetUserTenant(e)
-			userDeptID := e.Auth.GetString("dept_id")
-
-			query := e.Request.URL.Query()
-
-			if tenantIDField != nil {
-				if userTenantID == "" {
-					return fmt.Errorf("User tenant information is missing; cannot access tenant data")
-				}
-
-				oldFilter := query.Get("filter")
-				tenantFilter := fmt.Sprintf("%s=\"%s\"", tenantIDField.GetName(), userTenantID)
-				// Preserve existing query params; only update the filter value.
-				if oldFilter == "" {
-					query.Set("filter", tenantFilter)
-				} else {
-					query.Set("filter", fmt.Sprintf("(%s) && %s", oldFilter, tenantFilter))
-				}
-				e.Request.URL.RawQuery = query.Encode()
+			// Only the record list/view routes are scoped here; other collection routes
+			// (e.g. export) apply tools.DataScopeExpression themselves.
+			recordsPath := "/api/collections/" + collectionName + "/records"
+			path := strings.TrimSuffix(e.Request.URL.Path, "/")
+			recordID := e.Request.PathValue("id")
+			isList := path == recordsPath
+			isView := recordID != "" && path == recordsPath+"/"+recordID
+			if !isList && !isView {
+				return e.Next()
 			}
 
-			if IsSuperuserByApp(e) {
+			// The scope is applied as SQL (tenant + role data scopes, with department
+			// subtrees resolved by subqueries) rather than by rewriting ?filter=.
+			scope, err := tools.DataScopeExpression(e, collection, true)
+			if err != nil {
+				return e.ForbiddenError(err.Error(), nil)
+			}
+			if scope == nil {
 				return e.Next()
 			}
 
-			roles := system.GetAllRolesByUser(e, e.Auth.Id)
-
-			filters := []string{}
-
-			// Split each role's data permission logic into a separate function for reuse (e.g., dataScope == "6")
-			for _, role := range roles {
-				clause, stop := filterClauseForRole(e, e.App, role.ID, role.DataScope, getFieldName(createDeptField), getFieldName(createByField), userDeptID)
-				if stop {
-					// All data access: clear and stop
-					filters = []string{}
-					break
-				}
-				if clause != "" {
-					filters = append(filters, clause)
-				}
+			if isList {
+				return listRecordsInScope(e, collection)
 			}
 
-			if len(filters) > 0 {
-				if userDeptID == "" {
-					return fmt.Errorf("User department information is missing; cannot access department data")
-				}
-
-				oldFilter := query.Get("filter")
-				dsFilter := strings.Join(func() []string { return wrapEach(filters) }(), " || ")
-				createDeptFilter := fmt.Sprintf("(%s)", dsFilter)
-				// Merge with existing filter while preserving other query parameters.
-				if oldFilter == "" {
-					query.Set("filter", createDeptFilter)
-				} else {
-					query.Set("filter", fmt.Sprintf("(%s) && %s", oldFilter, createDeptFilter))
-				}
-				e.Request.URL.RawQuery = query.Encode()
+			if err := ensureRecordInScope(e, collection, recordID, scope); err != nil {
+				return err
 			}
-
 			return e.Next()
 		})
 
 		return se.Next()
 	})
+
+	// Data scopes are cached per user; drop them whenever their inputs change.
+	for _, name := range []string{"role", "user_role", "role_dept", "dept", "users", "user_post"} {
+		app.OnRecordAfterCreateSuccess(name).BindFunc(invalidateDataScope)
+		app.OnRecordAfterUpdateSuccess(name).BindFunc(invalidateDataScope)
+		app.OnRecordAfterDeleteSuccess(name).BindFunc(invalidateDataScope)
+	}
+}
+
+// invalidateDataScope clears the cached data scopes after a related record changes.
+func invalidateDataScope(e *core.RecordEvent) error {
+	tools.InvalidateDataScopeCache()
+	return e.Next()
 }
 
 // loadDataScopeWhitelist loads the list of collections that should skip data_scope from config/data_scope_whitelist.yml
@@ -220,116 +200,3 @@ func loadDataScopeWhitelist() map[string]struct{} {
 
 	return collMap
 }
-
-// --- helpers for data scope filtering ---
-
-// getFieldName safely extracts the field name from a possibly nil object (assuming it implements GetName() string)
-func getFieldName(f interface{}) string {
-	if f == nil {
-		return ""
-	}
-	type namer interface{ GetName() string }
-	if n, ok := f.(namer); ok {
-		return n.GetName()
-	}
-	return ""
-}
-
-// filterClauseForRole returns a filter clause based on the role's dataScope (with or without parentheses), and whether processing should stop ("1" means full access)
-func filterClauseForRole(e *core.RequestEvent, app core.App, roleID, dataScope, createDeptFieldName, createByFieldName, userDeptID string) (string, bool) {
-	switch dataScope {
-	case "1":
-		// All data access
-		return "", true
-	case "2":
-		// Custom data access
-		if createDeptFieldName != "" {
-			parts := partsFromRoleDept(app, createDeptFieldName, roleID)
-			if len(parts) > 0 {
-				return "(" + strings.Join(parts, " || ") + ")", false
-			}
-		}
-	case "3":
-		// Department-only data access
-		if createDeptFieldName != "" && userDeptID != "" {
-			return fmt.Sprintf("%s=\"%s\"", createDeptFieldName, userDeptID), false
-		}
-	case "4":
-		// Department and sub-departments data access
-		if createDeptFieldName != "" {
-			ids := getDeptIDsIncludingChildren(e, userDeptID)
-			parts := partsFromIDs(createDeptFieldName, ids)
-			if len(parts) > 0 {
-				return "(" + strings.Join(parts, " || ") + ")", false
-			}
-		}
-	case "5":
-		// Owner-only data access
-		if createByFieldName != "" {
-			return fmt.Sprintf("%s=\"%s\"", createByFieldName, e.Auth.Id), false
-		}
-	case "6":
-		// Combined: prefer merging custom access with department and sub-departments access
-		parts := []string{}
-		if createDeptFieldName != "" {
-			// Custom
-			parts = append(parts, partsFromRoleDept(app, createDeptFieldName, roleID)...)
-			// Department and sub-departments
-			ids := getDeptIDsIncludingChildren(e, userDeptID)
-			parts = append(parts, partsFromIDs(createDeptFieldName, ids)...)
-		}
-		if len(parts) > 0 {
-			return "(" + strings.Join(parts, " || ") + ")", false
-		}
-	}
-	return "", false
-}
-
-// partsFromRoleDept reads the dept list associated with a role from the role_dept table and constructs parts in the form field="id"
-func partsFromRoleDept(app core.App, fieldName, roleID string) []string {
-	if fieldName == "" || roleID == "" {
-		return nil
-	}
-	depts, _ := app.FindRecordsByFilter("role_dept", "role={:roleID}", "", 999, 0, dbx.Params{"roleID": roleID})
-	parts := make([]string, 0, len(depts))
-	for _, d := range depts {
-		deptID := d.GetString("dept")
-		parts = append(parts, fmt.Sprintf("%s=\"%s\"", fieldName, deptID))
-	}
-	return parts
-}
-
-// partsFromIDs constructs parts in the form field="id" based on a list of ids
-func partsFromIDs(fieldName string, ids []string) []string {
-	if fieldName == "" || len(ids) == 0 {
-		return nil
-	}
-	parts := make([]string, 0, len(ids))
-	for _, id := range ids {
-		parts = append(parts, fmt.Sprintf("%s=\"%s\"", fieldName, id))
-	}
-	return parts
-}
-
-// getDeptIDsIncludingChildren returns the list of ids including the user's department and its child departments
-func getDeptIDsIncludingChildren(e *core.RequestEvent, userDeptID string) []string {
-	ids := []string{}
-	if userDeptID == "" {
-		return ids
-	}
-	ids = append(ids, userDeptID)
-	depts := system.GetChildrensByDeptID(e, userDeptID)
-	for _, d := range depts {
-		ids = append(ids, d.ID)
-	}
-	return ids
-}
-
-// wrapEach adds a pair of parentheses around each filter element to facilitate joining
-func wrapEach(filters []string) []string {
-	out := make([]string, len(filters))
-	for i, f := range filters {
-		out[i] = "(" + f + ")"
-	}
-	return out
-}
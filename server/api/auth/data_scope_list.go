@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"net/http"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+// listRecordsInScope serves GET /api/collections/{collection}/records like the builtin
+// list API, additionally restricting the query with the caller's data scope (see tools.ScopedRecordQuery).
+// PocketBase offers no hook to alter the list query itself, so the handler is reproduced here.
+func listRecordsInScope(e *core.RequestEvent, collection *core.Collection) error {
+	query, fieldsResolver, err := tools.ScopedRecordQuery(e, collection)
+	if err != nil {
+		return err
+	}
+
+	searchProvider := search.NewProvider(fieldsResolver).Query(query)
+
+	if !collection.IsView() {
+		searchProvider.CountCol("_rowid_")
+	}
+
+	records := []*core.Record{}
+	result, err := searchProvider.ParseAndExec(e.Request.URL.Query().Encode(), &records)
+	if err != nil {
+		return e.BadRequestError("", err)
+	}
+
+	event := new(core.RecordsListRequestEvent)
+	event.RequestEvent = e
+	event.Collection = collection
+	event.Records = records
+	event.Result = result
+
+	return e.App.OnRecordsListRequest().Trigger(event, func(le *core.RecordsListRequestEvent) error {
+		if err := apis.EnrichRecords(le.RequestEvent, le.Records); err != nil {
+			return le.InternalServerError("Failed to enrich records", err)
+		}
+		return le.JSON(http.StatusOK, le.Result)
+	})
+}
+
+// ensureRecordInScope returns a 404 error when the record isn't visible under the data scope,
+// so single record views can't bypass the list restriction.
+func ensureRecordInScope(e *core.RequestEvent, collection *core.Collection, recordID string, scope dbx.Expression) error {
+	count := 0
+	err := e.App.DB().Select("count(*)").
+		From(collection.Name).
+		Where(dbx.HashExp{collection.Name + ".id": recordID}).
+		AndWhere(scope).
+		Row(&count)
+	if err != nil || count == 0 {
+		return e.NotFoundError("", nil)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// dataScopePreviewPayload is the body of POST /api/system/role/dataScope/preview.
+// RoleID selects a saved role; DataScope/DeptIDs override its settings (or describe an
+// unsaved role when RoleID is empty). DeptIDs = nil keeps the role's role_dept rows,
+// an empty list means "no departments".
+type dataScopePreviewPayload struct {
+	RoleID      string   `json:"role_id"`
+	DataScope   string   `json:"data_scope"`
+	DeptIDs     []string `json:"dept_ids"`
+	UserID      string   `json:"user_id"`
+	Collections []string `json:"collections"`
+}
+
+type dataScopePreviewDept struct {
+	ID       string `db:"id" json:"id"`
+	DeptName string `db:"dept_name" json:"dept_name"`
+	ParentID string `db:"parent_id" json:"parent_id"`
+}
+
+type dataScopePreviewCount struct {
+	Collection string `json:"collection"`
+	Total      int    `json:"total"`
+	Visible    int    `json:"visible"`
+}
+
+// RegisterDataScopePreview registers the data scope preview endpoint used by the role editor.
+// It reports which departments and how many rows per collection a sample user would see
+// with the given role, using the same expressions as the runtime filter (tools.FilterBuilderForRole).
+func RegisterDataScopePreview(app *pocketbase.PocketBase) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST("/api/system/role/dataScope/preview", func(e *core.RequestEvent) error {
+			if e.Auth == nil {
+				return e.UnauthorizedError("未登录或无权限", nil)
+			}
+
+			var payload dataScopePreviewPayload
+			if err := e.BindBody(&payload); err != nil {
+				return e.BadRequestError("无效的请求体", err)
+			}
+			if payload.UserID == "" {
+				return e.BadRequestError("缺少用户ID", nil)
+			}
+
+			superuser := IsSuperuser(e)
+			tenantID := tools.GetUserTenant(e)
+
+			user, err := e.App.FindRecordById("users", payload.UserID)
+			if err != nil {
+				return e.NotFoundError("用户不存在", err)
+			}
+			if !superuser && user.GetString("tenant_id") != tenantID {
+				return e.ForbiddenError("无权预览其他租户的用户", nil)
+			}
+
+			role := tools.DataScopeRole{DataScope: payload.DataScope, DeptIDs: payload.DeptIDs}
+			if payload.RoleID != "" {
+				roleRecord, err := e.App.FindRecordById("role", payload.RoleID)
+				if err != nil {
+					return e.NotFoundError("角色不存在", err)
+				}
+				if !superuser && roleRecord.GetString("tenant_id") != tenantID {
+					return e.ForbiddenError("无权预览其他租户的角色", nil)
+				}
+				role.ID = roleRecord.Id
+				if role.DataScope == "" {
+					role.DataScope = roleRecord.GetString("data_scope")
+				}
+			}
+			if !isValidDataScope(e.App, role.DataScope) {
+				return e.BadRequestError("无效的数据权限范围", nil)
+			}
+
+			userTenantID := user.GetString("tenant_id")
+			userDeptID := user.GetString("dept_id")
+
+			depts, err := previewScopeDepts(e.App, role, user.Id, userDeptID, userTenantID)
+			if err != nil {
+				return e.InternalServerError("查询部门失败", err)
+			}
+
+			counts, err := previewScopeCounts(e.App, role, user.Id, userDeptID, userTenantID, payload.Collections, superuser)
+			if err != nil {
+				var apiErr *router.ApiError
+				if errors.As(err, &apiErr) {
+					return apiErr
+				}
+				return e.BadRequestError("统计数据失败", err)
+			}
+
+			return tools.JSONSuccess(e, map[string]any{
+				"role_id":    role.ID,
+				"data_scope": role.DataScope,
+				"user_id":    user.Id,
+				"depts":      depts,
+				"counts":     counts,
+			})
+		}).BindFunc(RBAC("system:role:edit"))
+
+		return se.Next()
+	})
+}
+
+// isValidDataScope checks scope against the values of the role.data_scope select field.
+func isValidDataScope(app core.App, scope string) bool {
+	if scope == "" {
+		return false
+	}
+	coll, err := app.FindCachedCollectionByNameOrId("role")
+	if err != nil {
+		return false
+	}
+	field, ok := coll.Fields.GetByName("data_scope").(*core.SelectField)
+	if !ok {
+		return false
+	}
+	return slices.Contains(field.Values, scope)
+}
+
+// previewScopeDepts lists the departments of the user's tenant covered by the role.
+// Department based scopes are evaluated against dept.id itself; scopes that are not
+// department based (e.g. "5", own data only) cover no department.
+func previewScopeDepts(app core.App, role tools.DataScopeRole, userID, userDeptID, tenantID string) ([]dataScopePreviewDept, error) {
+	depts := []dataScopePreviewDept{}
+
+	fields := tools.DataScopeFields{Dept: tools.DataScopeField{Name: "id", Table: "dept"}}
+	exp, stop := tools.FilterBuilderForRole(app, role, fields, userID, userDeptID)
+	if !stop && exp == nil {
+		return depts, nil
+	}
+
+	q := app.DB().Select("dept.id", "dept.dept_name", "dept.parent_id").
+		From("dept").
+		Where(dbx.HashExp{"dept.tenant_id": tenantID}).
+		OrderBy("dept.parent_id ASC", "dept.order_num ASC")
+	if exp != nil {
+		q.AndWhere(exp)
+	}
+
+	err := q.All(&depts)
+	return depts, err
+}
+
+// previewScopeCounts returns, per collection, the rows in the user's tenant and those
+// visible under the role. Without explicit names, every non-system collection scoped
+// by department or owner (and not in the data scope whitelist) is reported.
+// Explicit names must refer to collections accepted by isPreviewableCollection,
+// otherwise a 400 error is returned (system and unscoped collections would leak
+// row counts across tenants).
+func previewScopeCounts(app core.App, role tools.DataScopeRole, userID, userDeptID, tenantID string, names []string, superuser bool) ([]dataScopePreviewCount, error) {
+	var collections []*core.Collection
+	if len(names) > 0 {
+		for _, name := range names {
+			coll, err := app.FindCachedCollectionByNameOrId(name)
+			if err != nil || !isPreviewableCollection(coll, superuser) {
+				return nil, apis.NewBadRequestError("不支持预览的集合："+name, nil)
+			}
+			collections = append(collections, coll)
+		}
+	} else {
+		all, err := app.FindAllCollections(core.CollectionTypeBase, core.CollectionTypeAuth)
+		if err != nil {
+			return nil, err
+		}
+		whitelist := loadDataScopeWhitelist()
+		for _, coll := range all {
+			if !isPreviewableCollection(coll, superuser) {
+				continue
+			}
+			if _, ok := whitelist[coll.Name]; ok {
+				continue
+			}
+			fields := tools.GetDataScopeFields(coll)
+			if fields.Dept.IsZero() && fields.Owner.IsZero() {
+				continue
+			}
+			collections = append(collections, coll)
+		}
+	}
+
+	counts := make([]dataScopePreviewCount, 0, len(collections))
+	for _, coll := range collections {
+		fields := tools.GetDataScopeFields(coll).WithTable(coll.Name)
+
+		tenantExp := fields.Tenant.ExpressionIn(tenantID)
+
+		count := dataScopePreviewCount{Collection: coll.Name}
+		if err := countScopeRows(app, coll.Name, tenantExp, &count.Total); err != nil {
+			return nil, err
+		}
+
+		exp, stop := tools.FilterBuilderForRole(app, role, fields, userID, userDeptID)
+		if stop || exp == nil {
+			count.Visible = count.Total
+		} else {
+			visibleExp := exp
+			if tenantExp != nil {
+				visibleExp = dbx.And(tenantExp, dbx.Enclose(exp))
+			}
+			if err := countScopeRows(app, coll.Name, visibleExp, &count.Visible); err != nil {
+				return nil, err
+			}
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, nil
+}
+
+// isPreviewableCollection reports whether row counts of coll may be previewed:
+// a non-system, non-view collection with a tenant, department or owner field.
+// Collections without a tenant field (e.g. tenant, menu) span all tenants and are
+// only previewable by superusers.
+func isPreviewableCollection(coll *core.Collection, superuser bool) bool {
+	if coll == nil || coll.System || coll.IsView() {
+		return false
+	}
+	fields := tools.GetDataScopeFields(coll)
+	if fields.Tenant.IsZero() {
+		return superuser && (!fields.Dept.IsZero() || !fields.Owner.IsZero())
+	}
+	return true
+}
+
+func countScopeRows(app core.App, table string, exp dbx.Expression, out *int) error {
+	q := app.DB().Select("count(*)").From(table)
+	if exp != nil {
+		q.Where(exp)
+	}
+	return q.Row(out)
+}
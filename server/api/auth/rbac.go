@@ -2,6 +2,7 @@ package auth
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"pocketbase-ruoyi/api/monitor"
@@ -34,8 +35,11 @@ func RegisterRBAC(app *pocketbase.PocketBase) {
 				}
 			}
 
+			// 超级管理员账号不做鉴权；但切换租户期间的写操作仍需记录操作日志
 			if e.Auth != nil && e.Auth.IsSuperuser() {
-				return e.Next()
+				if e.Request.Method == "GET" || !tools.IsTenantSwitched(e) {
+					return e.Next()
+				}
 			}
 			// 统一执行 e.Next()，并在末尾统一记录日志和返回
 			var blockErr error
@@ -118,6 +122,11 @@ func RegisterRBAC(app *pocketbase.PocketBase) {
 				}
 			}
 
+			// 租户 API 调用计量（平台超级管理员不计入）
+			if blockErr == nil && (e.Auth == nil || !e.Auth.IsSuperuser()) {
+				blockErr = meterTenantAPICall(e)
+			}
+
 			operParam := extractOperParam(e, 2048)
 			// 统一调用下一处理器并记录结果
 			start := time.Now()
@@ -150,6 +159,24 @@ func RegisterRBAC(app *pocketbase.PocketBase) {
 	})
 }
 
+// meterTenantAPICall 累加当前租户本月的 API 调用次数；
+// 租户套餐设置了 api_call_limit 且已超出时返回 429
+func meterTenantAPICall(e *core.RequestEvent) error {
+	tenantID := tools.GetUserTenant(e)
+	if e.Auth == nil {
+		tenantID = tools.GetDomainTenant(e)
+	}
+	if tenantID == "" {
+		return nil
+	}
+
+	calls := tools.IncTenantAPICalls(e.App, tenantID)
+	if limit := tools.TenantPackageLimit(e.App, tenantID, "api_call_limit"); limit > 0 && calls > limit {
+		return apis.NewTooManyRequestsError(fmt.Sprintf("租户本月接口调用次数已达上限（%d），请联系管理员升级套餐", limit), nil)
+	}
+	return nil
+}
+
 func mapBusinessType(action string) string {
 	// 业务类型编码：0=其它 1=新增 2=修改 3=删除 4=查列表 5=查详情 6=接口调用 10=导出 11=导入
 	switch strings.ToLower(action) {
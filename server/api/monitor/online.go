@@ -37,22 +37,11 @@ func RegisterMonitorOnline(app *pocketbase.PocketBase) {
 			return tools.JSONSuccess(e, result)
 		})
 		se.Router.DELETE("/api/monitor/online/{user_id}", func(e *core.RequestEvent) error {
-			collection, _ := e.App.FindCollectionByNameOrId("users")
-
 			userID := e.Request.PathValue("user_id")
 
-			record, err := app.FindRecordById("users", userID)
-			if err != nil {
+			if err := tools.RevokeUserSessions(e.App, userID); err != nil {
 				return err
 			}
-			record.RefreshTokenKey()
-
-			e.App.Save(record)
-
-			e.App.DB().Delete("_authOrigins", dbx.HashExp{
-				"collectionRef": collection.Id,
-				"recordRef":     userID,
-			}).Execute()
 
 			return tools.JSONSuccess(e, true)
 		})
@@ -9,6 +9,7 @@ import (
 // OperLogInput 操作日志入库参数
 type OperLogInput struct {
 	TenantID      string `json:"tenant_id" form:"tenant_id"`
+	OperTenantID  string `json:"oper_tenant_id" form:"oper_tenant_id"` // 操作人所属租户，切换租户操作时与 TenantID 不同
 	Title         string `json:"title" form:"title"`
 	BusinessType  string `json:"business_type" form:"business_type"` // 0/1/2/3
 	OperatorType  string `json:"operator_type" form:"operator_type"` // 0/1/2
@@ -49,6 +50,12 @@ func RecordOperLog(e *core.RequestEvent, in OperLogInput) error {
 	}
 	if in.OperName == "" && e.Auth != nil {
 		in.OperName = e.Auth.GetString("user_name")
+		if in.OperName == "" {
+			in.OperName = e.Auth.Email()
+		}
+	}
+	if in.OperTenantID == "" && e.Auth != nil {
+		in.OperTenantID = e.Auth.GetString("tenant_id")
 	}
 	if in.DeptName == "" && e.Auth != nil {
 		in.DeptName = e.Auth.GetString("dept_name")
@@ -61,6 +68,7 @@ func RecordOperLog(e *core.RequestEvent, in OperLogInput) error {
 
 	rec := core.NewRecord(col)
 	rec.Set("tenant_id", in.TenantID)
+	rec.Set("oper_tenant_id", in.OperTenantID)
 	rec.Set("title", in.Title)
 	if in.BusinessType != "" {
 		rec.Set("business_type", in.BusinessType)
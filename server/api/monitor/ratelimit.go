@@ -0,0 +1,371 @@
+package monitor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// 计数维度
+const (
+	rateLimitKeyIP     = "ip"
+	rateLimitKeyUser   = "user"
+	rateLimitKeyTenant = "tenant"
+	rateLimitKeyAPIKey = "apikey"
+)
+
+// rateLimitAPIKeyHeader 按 API Key 限流时读取的请求头
+const rateLimitAPIKeyHeader = "X-API-Key"
+
+// rateLimitRecentMax 保留的最近限流事件数
+const rateLimitRecentMax = 100
+
+// rateLimitRule 限流规则（config/rate_limit.yml）
+type rateLimitRule struct {
+	Name    string        `json:"name"`
+	Method  string        `json:"method"`  // 为空表示任意方法
+	Pattern string        `json:"pattern"` // 路径模式，* 匹配单段，结尾 /** 匹配任意子路径
+	Key     string        `json:"key"`
+	Window  time.Duration `json:"-"`
+	Limit   int           `json:"limit"`
+}
+
+// match 判断请求是否命中规则
+func (r *rateLimitRule) match(method, urlPath string) bool {
+	if r.Method != "" && r.Method != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Pattern, "/**"); ok {
+		return matchPathPrefix(prefix, urlPath)
+	}
+	ok, _ := path.Match(r.Pattern, strings.TrimSuffix(urlPath, "/"))
+	return ok
+}
+
+// matchPathPrefix 判断 urlPath 的前若干段是否匹配 prefix（可含通配符）
+func matchPathPrefix(prefix, urlPath string) bool {
+	n := strings.Count(prefix, "/")
+	parts := strings.SplitN(urlPath, "/", n+2)
+	if len(parts) < n+1 {
+		return false
+	}
+	ok, _ := path.Match(prefix, strings.Join(parts[:n+1], "/"))
+	return ok
+}
+
+// rateLimitEvent 一次被限流的请求
+type rateLimitEvent struct {
+	Rule   string    `json:"rule"`
+	Key    string    `json:"key"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Time   time.Time `json:"time"`
+}
+
+// rateLimitWindow 固定窗口计数
+type rateLimitWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// rateLimiter 内存限流器
+type rateLimiter struct {
+	mu      sync.Mutex
+	rules   []*rateLimitRule
+	apiKeys map[string]string // 已登记的 API Key -> 计数键（哈希前缀，不记录原文）
+	windows map[string]*rateLimitWindow
+	limited map[string]int64 // 规则名 -> 累计限流次数
+	recent  []rateLimitEvent
+}
+
+var limiter = newRateLimiter()
+
+func newRateLimiter() *rateLimiter {
+	rules, apiKeys := loadRateLimitRules()
+	return &rateLimiter{
+		rules:   rules,
+		apiKeys: apiKeys,
+		windows: map[string]*rateLimitWindow{},
+		limited: map[string]int64{},
+	}
+}
+
+// allow 为 rule+key 计数一次；超出时返回需等待的时长，first 表示本窗口内首次被限流
+func (l *rateLimiter) allow(rule *rateLimitRule, key string, now time.Time) (ok bool, retry time.Duration, first bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := rule.Name + "|" + key
+	w, exists := l.windows[id]
+	if !exists || !now.Before(w.resetAt) {
+		w = &rateLimitWindow{resetAt: now.Add(rule.Window)}
+		l.windows[id] = w
+	}
+	w.count++
+	if w.count <= rule.Limit {
+		return true, 0, false
+	}
+
+	l.limited[rule.Name]++
+	return false, w.resetAt.Sub(now), w.count == rule.Limit+1
+}
+
+// record 记录限流事件，供监控接口查看
+func (l *rateLimiter) record(ev rateLimitEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.recent = append(l.recent, ev)
+	if len(l.recent) > rateLimitRecentMax {
+		l.recent = l.recent[len(l.recent)-rateLimitRecentMax:]
+	}
+}
+
+// cleanup 清理已过期的计数窗口
+func (l *rateLimiter) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, w := range l.windows {
+		if !now.Before(w.resetAt) {
+			delete(l.windows, id)
+		}
+	}
+}
+
+// RegisterMonitorRateLimit 注册接口限流：
+//   - 按 config/rate_limit.yml 中的规则以 IP、用户、租户或 API Key 计数，超出返回 429 与 Retry-After；
+//   - 每个计数键在窗口内首次被限流时写入操作日志；
+//   - GET /api/monitor/rateLimit/list 查看规则、累计限流次数与最近的限流请求（超级管理员）。
+func RegisterMonitorRateLimit(app *pocketbase.PocketBase) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		// 在鉴权令牌与 X-Tenant-Id 解析之后、业务中间件之前执行
+		se.Router.Bind(&hook.Handler[*core.RequestEvent]{
+			Id:       "rateLimit",
+			Priority: apis.DefaultBodyLimitMiddlewarePriority + 2,
+			Func:     rateLimitMiddleware,
+		})
+
+		se.Router.GET("/api/monitor/rateLimit/list", func(e *core.RequestEvent) error {
+			if !e.Auth.IsSuperuser() && !tools.IsRoleSuperuser(app, e.Auth.Id) {
+				return e.ForbiddenError("仅超级管理员可查看限流信息", nil)
+			}
+
+			limiter.mu.Lock()
+			defer limiter.mu.Unlock()
+
+			rules := make([]map[string]any, 0, len(limiter.rules))
+			for _, r := range limiter.rules {
+				rules = append(rules, map[string]any{
+					"name":    r.Name,
+					"method":  r.Method,
+					"pattern": r.Pattern,
+					"key":     r.Key,
+					"window":  int64(r.Window / time.Second),
+					"limit":   r.Limit,
+					"limited": limiter.limited[r.Name],
+				})
+			}
+
+			recent := make([]rateLimitEvent, len(limiter.recent))
+			for i, ev := range limiter.recent {
+				recent[len(recent)-1-i] = ev
+			}
+
+			return tools.JSONSuccess(e, map[string]any{
+				"rules":  rules,
+				"recent": recent,
+			})
+		}).Bind(apis.RequireAuth())
+
+		return se.Next()
+	})
+
+	if err := app.Cron().Add("rateLimitCleanup", "*/5 * * * *", func() {
+		limiter.cleanup(time.Now())
+	}); err != nil {
+		app.Logger().Error("限流计数清理任务注册失败", "error", err)
+	}
+}
+
+func rateLimitMiddleware(e *core.RequestEvent) error {
+	if len(limiter.rules) == 0 || e.Request.Method == "OPTIONS" {
+		return e.Next()
+	}
+
+	now := time.Now()
+	method, urlPath := e.Request.Method, e.Request.URL.Path
+	for _, rule := range limiter.rules {
+		if !rule.match(method, urlPath) {
+			continue
+		}
+		key := rateLimitKey(e, rule.Key)
+		if key == "" {
+			continue
+		}
+
+		ok, retry, first := limiter.allow(rule, key, now)
+		if ok {
+			continue
+		}
+
+		limiter.record(rateLimitEvent{Rule: rule.Name, Key: key, Method: method, Path: urlPath, Time: now})
+		if first {
+			_ = RecordOperLog(e, OperLogInput{
+				Title:         "请求限流（" + rule.Name + "）",
+				BusinessType:  "0",
+				OperatorType:  "1",
+				Status:        "1",
+				Method:        rule.Key + ":" + key,
+				RequestMethod: method,
+				ErrorMsg:      fmt.Sprintf("%d 秒内超过 %d 次请求", int64(rule.Window/time.Second), rule.Limit),
+			})
+		}
+
+		seconds := int(math.Ceil(retry.Seconds()))
+		e.Response.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+		return apis.NewTooManyRequestsError("请求过于频繁，请稍后再试", nil)
+	}
+
+	return e.Next()
+}
+
+// rateLimitKey 按计数维度取当前请求的键；取不到（如匿名请求按用户计数）时返回空，规则不生效。
+// IP 维度使用 e.RealIP()，部署在反向代理后时需在 PocketBase 设置中配置可信代理头，否则取到的是代理地址。
+// API Key 维度只认 apiKeys 中登记的 Key，未登记或缺失时按 IP 计数，避免轮换请求头绕过限流
+func rateLimitKey(e *core.RequestEvent, kind string) string {
+	switch kind {
+	case rateLimitKeyIP:
+		return e.RealIP()
+	case rateLimitKeyUser:
+		if e.Auth != nil {
+			return e.Auth.Id
+		}
+	case rateLimitKeyTenant:
+		return tools.GetUserTenant(e)
+	case rateLimitKeyAPIKey:
+		if key, ok := limiter.apiKeys[strings.TrimSpace(e.Request.Header.Get(rateLimitAPIKeyHeader))]; ok {
+			return key
+		}
+		return e.RealIP()
+	}
+	return ""
+}
+
+// loadRateLimitRules 读取 config/rate_limit.yml（简单的行解析）中的规则与已登记的 API Key，
+// 文件不存在时不限流
+func loadRateLimitRules() ([]*rateLimitRule, map[string]string) {
+	apiKeys := map[string]string{}
+	data, err := os.ReadFile(filepath.Join("config", "rate_limit.yml"))
+	if err != nil {
+		return nil, apiKeys
+	}
+
+	// rules:
+	//   - name: login
+	//     pattern: "POST /api/collections/*/auth-with-password"
+	//     key: ip
+	//     window: 5m
+	//     limit: 10
+	// apiKeys:
+	//   - "key1"
+	rules := []*rateLimitRule{}
+	var cur *rateLimitRule
+	section := ""
+	for _, ln := range strings.Split(string(data), "\n") {
+		if i := strings.Index(ln, "#"); i >= 0 {
+			ln = ln[:i]
+		}
+		t := strings.TrimSpace(ln)
+		if t == "" {
+			continue
+		}
+		if !strings.HasPrefix(ln, " ") && !strings.HasPrefix(ln, "\t") && strings.HasSuffix(t, ":") {
+			section = strings.TrimSuffix(t, ":")
+			continue
+		}
+		if section == "apiKeys" {
+			if rest, ok := strings.CutPrefix(t, "-"); ok {
+				if key := strings.Trim(strings.TrimSpace(rest), "\"'"); key != "" {
+					sum := sha256.Sum256([]byte(key))
+					apiKeys[key] = "apikey:" + hex.EncodeToString(sum[:6])
+				}
+			}
+			continue
+		}
+		if section != "rules" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(t, "-"); ok {
+			cur = &rateLimitRule{}
+			rules = append(rules, cur)
+			t = strings.TrimSpace(rest)
+		}
+		if cur == nil {
+			continue
+		}
+
+		k, v, ok := strings.Cut(t, ":")
+		if !ok {
+			continue
+		}
+		v = strings.Trim(strings.TrimSpace(v), "\"'")
+		switch strings.TrimSpace(k) {
+		case "name":
+			cur.Name = v
+		case "pattern":
+			if method, p, ok := strings.Cut(v, " "); ok {
+				cur.Method, cur.Pattern = strings.ToUpper(method), strings.TrimSpace(p)
+			} else {
+				cur.Pattern = v
+			}
+		case "key":
+			cur.Key = strings.ToLower(v)
+		case "window":
+			cur.Window = parseRateLimitWindow(v)
+		case "limit":
+			cur.Limit, _ = strconv.Atoi(v)
+		}
+	}
+
+	valid := rules[:0]
+	for i, r := range rules {
+		if r.Pattern == "" || r.Window <= 0 || r.Limit <= 0 {
+			continue
+		}
+		switch r.Key {
+		case rateLimitKeyIP, rateLimitKeyUser, rateLimitKeyTenant, rateLimitKeyAPIKey:
+		default:
+			continue
+		}
+		if r.Name == "" {
+			r.Name = "rule" + strconv.Itoa(i+1)
+		}
+		valid = append(valid, r)
+	}
+	return valid, apiKeys
+}
+
+// parseRateLimitWindow 解析时间窗口：纯数字为秒，否则按 Go 时长格式（如 30s、5m、1h）
+func parseRateLimitWindow(v string) time.Duration {
+	if n, err := strconv.Atoi(v); err == nil {
+		return time.Duration(n) * time.Second
+	}
+	d, _ := time.ParseDuration(v)
+	return d
+}
@@ -3,7 +3,6 @@ package system
 import (
 	"fmt"
 	"sort"
-	"strings"
 
 	"pocketbase-ruoyi/tools"
 
@@ -49,7 +48,7 @@ func GetChildrensByDeptID(e *core.RequestEvent, deptID string) []Dept {
 	return rows
 }
 
-// RegisterSystemDept 注册 /api/system/user/deptTree 接口
+// RegisterSystemDept 注册 /api/system/user/deptTree 接口及部门移动、ancestors 维护、删除检查
 func RegisterSystemDept(app *pocketbase.PocketBase) {
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 		se.Router.GET("/api/system/user/deptTree", func(e *core.RequestEvent) error {
@@ -61,12 +60,13 @@ func RegisterSystemDept(app *pocketbase.PocketBase) {
 				return e.UnauthorizedError("未登录或无权限", nil)
 			}
 
-			// 查询有效部门（status=0 且 del_flag=0）
+			// 查询有效部门（status=0 且未删除）
 			var depts []Dept
 			q := e.App.DB().
 				Select("id", "dept_name", "parent_id", "order_num", "status", "del_flag").
 				From("dept").
 				Where(dbx.HashExp{"status": "0"}).
+				AndWhere(dbx.Not(dbx.HashExp{"del_flag": deptDelFlagDeleted})).
 				AndWhere(tools.BuildDataScopeExpression(e, "dept")).
 				OrderBy("parent_id ASC", "order_num ASC", "id ASC")
 
@@ -84,65 +84,8 @@ func RegisterSystemDept(app *pocketbase.PocketBase) {
 		return se.Next()
 	})
 
-	// 新增：创建成功后，基于 parent_id 设置当前记录 ancestors，并更新直接子节点的 ancestors
-	app.OnRecordAfterCreateSuccess("dept").BindFunc(func(e *core.RecordEvent) error {
-		if err := setAncestorsAndChildren(e.App, e.Record); err != nil {
-			return err
-		}
-		return e.Next()
-	})
-
-	// 新增：更新成功后，同步 ancestors 与直接子节点 ancestors
-	app.OnRecordAfterUpdateSuccess("dept").BindFunc(func(e *core.RecordEvent) error {
-		if err := setAncestorsAndChildren(e.App, e.Record); err != nil {
-			return err
-		}
-		return e.Next()
-	})
-}
-
-// setAncestorsAndChildren 根据记录的 parent_id 计算并设置自身 ancestors，同时更新所有直接子部门的 ancestors
-func setAncestorsAndChildren(app core.App, rec *core.Record) error {
-	// 1) 计算自身 ancestors
-	parentID := strings.TrimSpace(rec.GetString("parent_id"))
-	var newAncestors string
-
-	if parentID == "" || parentID == "0" {
-		newAncestors = "0"
-	} else {
-		parent, err := app.FindRecordById("dept", parentID)
-		if err != nil {
-			// 若父级不存在，降级为根（0）
-			newAncestors = "0"
-		} else {
-			pa := strings.Trim(parent.GetString("ancestors"), ",")
-			if pa == "" {
-				pa = "0"
-			}
-			newAncestors = pa + "," + parent.Id
-		}
-	}
-
-	if rec.GetString("ancestors") != newAncestors {
-		rec.Set("ancestors", newAncestors)
-		if err := app.Save(rec); err != nil {
-			return err
-		}
-	}
-
-	// 2) 更新直接子部门的 ancestors = 本记录 ancestors + "," + 本记录 id
-	children := []*core.Record{}
-	if err := app.RecordQuery("dept").Where(dbx.HashExp{"parent_id": rec.Id}).All(&children); err != nil {
-		return err
-	}
-	for _, ch := range children {
-		ch.Set("ancestors", newAncestors+","+rec.Id)
-		if err := app.Save(ch); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	registerDeptMove(app)
+	registerDeptDelete(app)
 }
 
 func uniqueDepts(in []Dept) []Dept {
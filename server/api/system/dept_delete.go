@@ -0,0 +1,197 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// deptDelFlagDeleted 部门已删除标记（del_flag）
+const deptDelFlagDeleted = "1"
+
+// deptRef 删除检查中引用部门的记录
+type deptRef struct {
+	ID   string `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+}
+
+// deptDeleteImpact 部门被引用的情况
+type deptDeleteImpact struct {
+	DeptID   string    `json:"dept_id"`
+	DeptName string    `json:"dept_name"`
+	Children []deptRef `json:"children"` // 未删除的直接下级部门
+	Users    []deptRef `json:"users"`    // 部门下未删除的用户
+	Posts    []deptRef `json:"posts"`    // 归属部门的未删除岗位
+	Roles    []deptRef `json:"roles"`    // 数据权限（role_dept）包含该部门的角色
+}
+
+// blocked 存在下级部门、用户、岗位或角色数据权限引用时，不允许删除
+func (i *deptDeleteImpact) blocked() bool {
+	return len(i.Children) > 0 || len(i.Users) > 0 || len(i.Posts) > 0 || len(i.Roles) > 0
+}
+
+func (i *deptDeleteImpact) message() string {
+	parts := []string{}
+	if n := len(i.Children); n > 0 {
+		parts = append(parts, fmt.Sprintf("下级部门 %d 个（%s）", n, deptRefNames(i.Children)))
+	}
+	if n := len(i.Users); n > 0 {
+		parts = append(parts, fmt.Sprintf("用户 %d 个（%s）", n, deptRefNames(i.Users)))
+	}
+	if n := len(i.Posts); n > 0 {
+		parts = append(parts, fmt.Sprintf("岗位 %d 个（%s）", n, deptRefNames(i.Posts)))
+	}
+	if n := len(i.Roles); n > 0 {
+		parts = append(parts, fmt.Sprintf("角色数据权限 %d 个（%s）", n, deptRefNames(i.Roles)))
+	}
+	return fmt.Sprintf("部门「%s」存在%s，不允许删除", i.DeptName, strings.Join(parts, "、"))
+}
+
+// deptRefNames 拼接名称，最多列出 5 个
+func deptRefNames(refs []deptRef) string {
+	const limit = 5
+	names := make([]string, 0, limit)
+	for i, r := range refs {
+		if i == limit {
+			names = append(names, "…")
+			break
+		}
+		names = append(names, r.Name)
+	}
+	return strings.Join(names, "、")
+}
+
+// registerDeptDelete 注册部门删除：
+//   - 删除部门请求改为软删除（del_flag=1），存在下级部门、用户、岗位或角色数据权限引用时拒绝并说明原因；
+//   - 通过修改 del_flag 删除部门时做相同检查；
+//   - GET /api/system/dept/list/exclude/{deptId} 返回可作为该部门上级的部门（排除自身、下级及已删除部门，需 dept:query）；
+//   - 已删除部门由 dept 集合的 listRule/viewRule（del_flag!='1'）在服务端隐藏。
+func registerDeptDelete(app *pocketbase.PocketBase) {
+	app.OnRecordDeleteRequest("dept").BindFunc(softDeleteDept)
+
+	app.OnRecordUpdateRequest("dept").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Record.GetString("del_flag") != deptDelFlagDeleted ||
+			e.Record.Original().GetString("del_flag") == deptDelFlagDeleted {
+			return e.Next()
+		}
+		impact, err := findDeptDeleteImpact(e.App, e.Record)
+		if err != nil {
+			return e.InternalServerError("检查部门引用失败", err)
+		}
+		if impact.blocked() {
+			return e.BadRequestError(impact.message(), nil)
+		}
+		return e.Next()
+	})
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/system/dept/list/exclude/{deptId}", func(e *core.RequestEvent) error {
+			if e.Auth == nil {
+				return e.UnauthorizedError("未登录或无权限", nil)
+			}
+			if !hasDeptPermission(app, e, "dept:query") {
+				return e.ForbiddenError("权限不足", nil)
+			}
+
+			deptID := e.Request.PathValue("deptId")
+			depts := []Dept{}
+			err := e.App.DB().
+				Select("id", "dept_name", "parent_id", "ancestors", "order_num", "status", "del_flag").
+				From("dept").
+				Where(dbx.Not(dbx.HashExp{"del_flag": deptDelFlagDeleted})).
+				AndWhere(dbx.NewExp(
+					"id NOT IN (WITH RECURSIVE sub(id) AS ("+
+						"SELECT {:deptId} UNION SELECT d.id FROM dept d INNER JOIN sub s ON d.parent_id = s.id"+
+						") SELECT id FROM sub)",
+					dbx.Params{"deptId": deptID},
+				)).
+				AndWhere(tools.BuildDataScopeExpression(e, "dept")).
+				OrderBy("parent_id ASC", "order_num ASC", "id ASC").
+				All(&depts)
+			if err != nil {
+				return e.InternalServerError("查询部门失败", err)
+			}
+			return tools.JSONSuccess(e, depts)
+		})
+
+		return se.Next()
+	})
+}
+
+// softDeleteDept 删除部门时检查引用，无引用时仅标记删除
+func softDeleteDept(e *core.RecordRequestEvent) error {
+	if e.Record.GetString("del_flag") == deptDelFlagDeleted {
+		return e.NoContent(204)
+	}
+
+	impact, err := findDeptDeleteImpact(e.App, e.Record)
+	if err != nil {
+		return e.InternalServerError("检查部门引用失败", err)
+	}
+	if impact.blocked() {
+		return e.BadRequestError(impact.message(), nil)
+	}
+
+	e.Record.Set("del_flag", deptDelFlagDeleted)
+	if err := e.App.Save(e.Record); err != nil {
+		return e.BadRequestError("删除部门失败", err)
+	}
+	return e.NoContent(204)
+}
+
+// findDeptDeleteImpact 统计引用部门的下级部门、用户、岗位与角色
+func findDeptDeleteImpact(app core.App, dept *core.Record) (*deptDeleteImpact, error) {
+	impact := &deptDeleteImpact{
+		DeptID:   dept.Id,
+		DeptName: dept.GetString("dept_name"),
+		Children: []deptRef{},
+		Users:    []deptRef{},
+		Posts:    []deptRef{},
+		Roles:    []deptRef{},
+	}
+	notDeleted := dbx.Not(dbx.HashExp{"del_flag": deptDelFlagDeleted})
+
+	err := app.DB().Select("id", "dept_name as name").From("dept").
+		Where(dbx.HashExp{"parent_id": dept.Id}).
+		AndWhere(notDeleted).
+		OrderBy("order_num ASC", "id ASC").
+		All(&impact.Children)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.DB().Select("id", "user_name as name").From("users").
+		Where(dbx.HashExp{"dept_id": dept.Id}).
+		AndWhere(notDeleted).
+		OrderBy("user_name ASC").
+		All(&impact.Users)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.DB().Select("id", "post_name as name").From("post").
+		Where(dbx.HashExp{"dept_id": dept.Id}).
+		AndWhere(notDeleted).
+		OrderBy("post_sort ASC", "id ASC").
+		All(&impact.Posts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.DB().Select("r.id", "r.role_name as name").Distinct(true).From("role as r").
+		InnerJoin("role_dept as rd", dbx.NewExp("rd.role = r.id")).
+		Where(dbx.HashExp{"rd.dept": dept.Id}).
+		AndWhere(dbx.Not(dbx.HashExp{"r.del_flag": deptDelFlagDeleted})).
+		OrderBy("r.role_sort ASC").
+		All(&impact.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return impact, nil
+}
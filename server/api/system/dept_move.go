@@ -0,0 +1,362 @@
+package system
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"pocketbase-ruoyi/api/system/menu"
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// deptMovePayload PUT /api/system/dept/move 请求体
+type deptMovePayload struct {
+	DeptID   string `json:"dept_id"`
+	ParentID string `json:"parent_id"`
+	OrderNum *int   `json:"order_num"`
+}
+
+// deptAncestorsReport ancestors 重建结果
+type deptAncestorsReport struct {
+	Total   int      `json:"total"`
+	Changed int      `json:"changed"`
+	Broken  []string `json:"broken"` // 上级不存在或处在循环中、重置为根部门（parent_id 置为 0）的部门ID
+}
+
+// registerDeptMove 注册部门移动与 ancestors 维护：
+//   - 新增/修改部门时按 parent_id 计算 ancestors；上级变更时拒绝移动到自身或下级部门之下，
+//     并在同一事务中重写整个子树的 ancestors；
+//   - PUT /api/system/dept/move 移动部门（含子树）到新的上级部门；
+//   - 命令 dept-ancestors [--dry-run] 按 parent_id 重建全部部门的 ancestors。
+func registerDeptMove(app *pocketbase.PocketBase) {
+	app.OnRecordCreateExecute("dept").BindFunc(func(e *core.RecordEvent) error {
+		parentID := deptParentID(e.Record)
+		if parentID == e.Record.Id {
+			return apis.NewBadRequestError("上级部门不能是自己", nil)
+		}
+		ancestors, err := deptAncestors(e.App, parentID)
+		if err != nil {
+			return err
+		}
+		e.Record.Set("ancestors", ancestors)
+		return e.Next()
+	})
+
+	app.OnRecordUpdateExecute("dept").BindFunc(syncDeptSubtree)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.PUT("/api/system/dept/move", func(e *core.RequestEvent) error {
+			var payload deptMovePayload
+			if err := e.BindBody(&payload); err != nil {
+				return e.BadRequestError("无效的请求体", err)
+			}
+			if !canEditDept(app, e) {
+				return e.ForbiddenError("权限不足", nil)
+			}
+
+			dept, err := e.App.FindRecordById("dept", payload.DeptID)
+			if err != nil {
+				return e.NotFoundError("部门不存在", err)
+			}
+			parentID := strings.TrimSpace(payload.ParentID)
+			if parentID == "" {
+				parentID = "0"
+			}
+
+			// 部门与目标上级都需在当前用户的数据权限内
+			ids := []any{dept.Id}
+			if parentID != "0" {
+				ids = append(ids, parentID)
+			}
+			visible := 0
+			err = e.App.DB().Select("count(*)").From("dept").
+				Where(dbx.In("id", ids...)).
+				AndWhere(tools.BuildDataScopeExpression(e, "dept")).
+				Row(&visible)
+			if err != nil {
+				return e.InternalServerError("查询部门失败", err)
+			}
+			if visible != len(ids) {
+				return e.ForbiddenError("没有权限访问该部门", nil)
+			}
+
+			dept.Set("parent_id", parentID)
+			if payload.OrderNum != nil {
+				dept.Set("order_num", *payload.OrderNum)
+			}
+			if err := e.App.Save(dept); err != nil {
+				return e.BadRequestError("移动部门失败："+err.Error(), nil)
+			}
+			return tools.JSONSuccess(e, dept)
+		}).Bind(apis.RequireAuth())
+
+		return se.Next()
+	})
+
+	cmd := &cobra.Command{
+		Use:   "dept-ancestors",
+		Short: "按 parent_id 重建全部部门的 ancestors",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			var report *deptAncestorsReport
+			err := app.RunInTransaction(func(txApp core.App) error {
+				var err error
+				report, err = rebuildDeptAncestors(txApp, dryRun)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if !dryRun {
+				tools.InvalidateDataScopeCache()
+			}
+
+			for _, id := range report.Broken {
+				if dryRun {
+					fmt.Printf("部门 %s 的上级不存在或存在循环，将重置为根部门\n", id)
+				} else {
+					fmt.Printf("部门 %s 的上级不存在或存在循环，已重置为根部门\n", id)
+				}
+			}
+			fmt.Printf("共 %d 个部门，需更新 %d 个", report.Total, report.Changed)
+			if dryRun {
+				fmt.Print("（dry-run，未写入）")
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+	cmd.Flags().Bool("dry-run", false, "只统计需要更新的部门，不写入")
+	app.RootCmd.AddCommand(cmd)
+}
+
+// syncDeptSubtree 上级部门变更时校验循环并计算 ancestors，与子树 ancestors 的重写在同一事务中完成
+func syncDeptSubtree(e *core.RecordEvent) error {
+	parentID := deptParentID(e.Record)
+	oldAncestors := e.Record.Original().GetString("ancestors")
+	if parentID == deptParentID(e.Record.Original()) && oldAncestors != "" {
+		// 上级未变更时 ancestors 不允许被单独修改
+		e.Record.Set("ancestors", oldAncestors)
+		return e.Next()
+	}
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		original := e.App
+		e.App = txApp
+		defer func() { e.App = original }()
+
+		if err := checkDeptCycle(txApp, e.Record.Id, parentID); err != nil {
+			return err
+		}
+		if parentID != "0" {
+			parent, err := txApp.FindRecordById("dept", parentID)
+			if err != nil {
+				return apis.NewBadRequestError("上级部门不存在", err)
+			}
+			if parent.GetString("tenant_id") != e.Record.GetString("tenant_id") {
+				return apis.NewBadRequestError("不能移动到其他租户的部门下", nil)
+			}
+		}
+
+		ancestors, err := deptAncestors(txApp, parentID)
+		if err != nil {
+			return err
+		}
+		e.Record.Set("ancestors", ancestors)
+		if err := e.Next(); err != nil {
+			return err
+		}
+
+		return rewriteDeptSubtree(txApp, e.Record.Id, ancestors+","+e.Record.Id)
+	})
+}
+
+// deptParentID 读取上级部门ID，未设置时为 0（根部门）
+func deptParentID(rec *core.Record) string {
+	if p := strings.TrimSpace(rec.GetString("parent_id")); p != "" {
+		return p
+	}
+	return "0"
+}
+
+// deptAncestors 根据上级部门计算 ancestors：根部门为 0，否则为 上级ancestors,上级ID
+func deptAncestors(app core.App, parentID string) (string, error) {
+	if parentID == "" || parentID == "0" {
+		return "0", nil
+	}
+	parent, err := app.FindRecordById("dept", parentID)
+	if err != nil {
+		return "", apis.NewBadRequestError("上级部门不存在", err)
+	}
+	if parent.GetString("del_flag") == deptDelFlagDeleted {
+		return "", apis.NewBadRequestError("上级部门已删除", nil)
+	}
+	pa := strings.Trim(parent.GetString("ancestors"), ",")
+	if pa == "" {
+		pa = "0"
+	}
+	return pa + "," + parent.Id, nil
+}
+
+// checkDeptCycle 沿 parent_id 向上查找新上级的祖先链，包含部门自身时拒绝移动
+func checkDeptCycle(app core.App, deptID, parentID string) error {
+	seen := map[string]struct{}{}
+	for id := parentID; id != "" && id != "0"; {
+		if id == deptID {
+			return apis.NewBadRequestError("不能将部门移动到自身或其下级部门之下", nil)
+		}
+		if _, ok := seen[id]; ok {
+			return apis.NewBadRequestError("部门层级存在循环，请先执行 dept-ancestors 修复", nil)
+		}
+		seen[id] = struct{}{}
+
+		next := ""
+		err := app.DB().Select("parent_id").From("dept").
+			Where(dbx.HashExp{"id": id}).
+			Row(&next)
+		if errors.Is(err, sql.ErrNoRows) {
+			break // 上级链在不存在的部门处结束
+		}
+		if err != nil {
+			return err
+		}
+		id = strings.TrimSpace(next)
+	}
+	return nil
+}
+
+// rewriteDeptSubtree 按 parent_id 逐层重写下级部门的 ancestors（prefix 为直接下级的 ancestors）。
+// 直接更新数据表，不再逐条触发部门保存钩子
+func rewriteDeptSubtree(app core.App, rootID, prefix string) error {
+	type level struct{ id, ancestors string }
+	queue := []level{{rootID, prefix}}
+	seen := map[string]struct{}{rootID: {}}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		children := []string{}
+		err := app.DB().Select("id").From("dept").
+			Where(dbx.HashExp{"parent_id": cur.id}).
+			Column(&children)
+		if err != nil {
+			return err
+		}
+		for _, id := range children {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+
+			_, err := app.DB().Update("dept", dbx.Params{"ancestors": cur.ancestors}, dbx.HashExp{"id": id}).Execute()
+			if err != nil {
+				return err
+			}
+			queue = append(queue, level{id, cur.ancestors + "," + id})
+		}
+	}
+	return nil
+}
+
+// rebuildDeptAncestors 按 parent_id 重新计算全部部门的 ancestors；
+// 上级不存在或处在循环中的部门重置为根部门（parent_id 置为 0）并在报告中列出，其下级部门保持原上级
+func rebuildDeptAncestors(app core.App, dryRun bool) (*deptAncestorsReport, error) {
+	depts := []Dept{}
+	if err := app.DB().Select("id", "parent_id", "ancestors").From("dept").All(&depts); err != nil {
+		return nil, err
+	}
+
+	parents := make(map[string]string, len(depts))
+	for _, d := range depts {
+		parents[d.ID] = strings.TrimSpace(d.ParentID)
+	}
+
+	report := &deptAncestorsReport{Total: len(depts), Broken: []string{}}
+	broken := map[string]struct{}{}
+	for _, d := range depts {
+		if deptParentBroken(parents, d.ID) {
+			broken[d.ID] = struct{}{}
+			report.Broken = append(report.Broken, d.ID)
+		}
+	}
+	for id := range broken {
+		parents[id] = "0"
+	}
+
+	for _, d := range depts {
+		chain := []string{}
+		for id := parents[d.ID]; id != "" && id != "0"; id = parents[id] {
+			chain = append(chain, id)
+		}
+		slices.Reverse(chain)
+		ancestors := strings.Join(append([]string{"0"}, chain...), ",")
+
+		params := dbx.Params{}
+		if ancestors != d.Ancestors {
+			params["ancestors"] = ancestors
+		}
+		if _, ok := broken[d.ID]; ok {
+			params["parent_id"] = "0"
+		}
+		if len(params) == 0 {
+			continue
+		}
+
+		report.Changed++
+		if dryRun {
+			continue
+		}
+		if _, err := app.DB().Update("dept", params, dbx.HashExp{"id": d.ID}).Execute(); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// deptParentBroken 判断部门的上级不存在，或部门本身处在 parent_id 循环中
+func deptParentBroken(parents map[string]string, deptID string) bool {
+	parentID := parents[deptID]
+	if parentID == "" || parentID == "0" {
+		return false
+	}
+	if _, ok := parents[parentID]; !ok {
+		return true
+	}
+
+	seen := map[string]struct{}{}
+	for id := parentID; id != "" && id != "0"; id = parents[id] {
+		if id == deptID {
+			return true
+		}
+		if _, ok := seen[id]; ok {
+			return false // 循环在上级链中，由循环内的部门重置
+		}
+		seen[id] = struct{}{}
+	}
+	return false
+}
+
+// canEditDept 超级管理员或拥有部门修改权限（dept:edit）
+func canEditDept(app *pocketbase.PocketBase, e *core.RequestEvent) bool {
+	return hasDeptPermission(app, e, "dept:edit")
+}
+
+// hasDeptPermission 超级管理员或拥有部门集合的指定权限（与集合接口的 RBAC 校验一致，如列表为 dept:query）
+func hasDeptPermission(app *pocketbase.PocketBase, e *core.RequestEvent, perm string) bool {
+	if e.Auth.IsSuperuser() || tools.IsRoleSuperuser(app, e.Auth.Id) {
+		return true
+	}
+	perms := menu.GetAllPermissionsByUser(e, e.Auth.Id)
+	return slices.Contains(perms, "*:*:*") || slices.Contains(perms, perm)
+}
@@ -0,0 +1,180 @@
+package system
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// deptFixtureCollection is the part of a collections.json entry used by the fixture.
+type deptFixtureCollection struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Fields json.RawMessage `json:"fields"`
+}
+
+// newDeptFixture creates a migrated sqlite app in a temp dir with the dept collection of
+// ../../collections.json (fields only, same approach as the tools data scope fixture).
+func newDeptFixture(t *testing.T) core.App {
+	t.Helper()
+
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = app.ResetBootstrapState() })
+	if err := app.RunAllMigrations(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile("../../collections.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs := []deptFixtureCollection{}
+	if err := json.Unmarshal(raw, &defs); err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(defs, func(d deptFixtureCollection) bool { return d.Name == "dept" })
+	if i < 0 {
+		t.Fatal("collection dept not found in collections.json")
+	}
+
+	fields := core.NewFieldsList()
+	if err := json.Unmarshal(defs[i].Fields, &fields); err != nil {
+		t.Fatal(err)
+	}
+	coll := core.NewBaseCollection("dept", defs[i].ID)
+	coll.Fields = fields
+	if err := app.SaveNoValidate(coll); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func TestRebuildDeptAncestors(t *testing.T) {
+	app := newDeptFixture(t)
+
+	type dept struct{ id, parent, ancestors string }
+	scenarios := []struct {
+		name        string
+		depts       []dept
+		dryRun      bool
+		wantBroken  []string
+		wantChanged int
+		want        []dept
+	}{
+		{
+			name:        "healthy tree with stale ancestors",
+			depts:       []dept{{"100", "0", "0"}, {"101", "100", ""}, {"102", "101", "0,999"}},
+			wantBroken:  []string{},
+			wantChanged: 2,
+			want:        []dept{{"100", "0", "0"}, {"101", "100", "0,100"}, {"102", "101", "0,100,101"}},
+		},
+		{
+			name:        "missing parent",
+			depts:       []dept{{"a", "missing", "0,missing"}, {"b", "a", "0,missing,a"}},
+			wantBroken:  []string{"a"},
+			wantChanged: 2,
+			want:        []dept{{"a", "0", "0"}, {"b", "a", "0,a"}},
+		},
+		{
+			name:        "missing parent dry-run",
+			depts:       []dept{{"a", "missing", "0,missing"}, {"b", "a", "0,missing,a"}},
+			dryRun:      true,
+			wantBroken:  []string{"a"},
+			wantChanged: 2,
+			want:        []dept{{"a", "missing", "0,missing"}, {"b", "a", "0,missing,a"}},
+		},
+		{
+			name:        "2-node cycle",
+			depts:       []dept{{"a", "b", ""}, {"b", "a", ""}},
+			wantBroken:  []string{"a", "b"},
+			wantChanged: 2,
+			want:        []dept{{"a", "0", "0"}, {"b", "0", "0"}},
+		},
+		{
+			name:        "3-node cycle",
+			depts:       []dept{{"a", "c", ""}, {"b", "a", ""}, {"c", "b", ""}},
+			wantBroken:  []string{"a", "b", "c"},
+			wantChanged: 3,
+			want:        []dept{{"a", "0", "0"}, {"b", "0", "0"}, {"c", "0", "0"}},
+		},
+		{
+			name:        "descendants of a cycle keep their parent",
+			depts:       []dept{{"a", "b", ""}, {"b", "a", ""}, {"c", "a", ""}, {"d", "c", ""}},
+			wantBroken:  []string{"a", "b"},
+			wantChanged: 4,
+			want:        []dept{{"a", "0", "0"}, {"b", "0", "0"}, {"c", "a", "0,a"}, {"d", "c", "0,a,c"}},
+		},
+		{
+			name:        "cycle dry-run",
+			depts:       []dept{{"a", "b", ""}, {"b", "a", ""}, {"c", "a", ""}},
+			dryRun:      true,
+			wantBroken:  []string{"a", "b"},
+			wantChanged: 3,
+			want:        []dept{{"a", "b", ""}, {"b", "a", ""}, {"c", "a", ""}},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			if _, err := app.DB().Delete("dept", nil).Execute(); err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range s.depts {
+				_, err := app.DB().Insert("dept", dbx.Params{
+					"id": d.id, "parent_id": d.parent, "ancestors": d.ancestors,
+					"tenant_id": "000000", "dept_name": "dept-" + d.id, "status": "0", "del_flag": "0",
+				}).Execute()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := rebuildDeptAncestors(app, s.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(report.Broken)
+			if !slices.Equal(report.Broken, s.wantBroken) {
+				t.Fatalf("expected broken %v, got %v", s.wantBroken, report.Broken)
+			}
+			if report.Changed != s.wantChanged {
+				t.Fatalf("expected %d changed, got %d", s.wantChanged, report.Changed)
+			}
+			if report.Total != len(s.depts) {
+				t.Fatalf("expected total %d, got %d", len(s.depts), report.Total)
+			}
+
+			for _, w := range s.want {
+				got := dept{id: w.id}
+				err := app.DB().Select("parent_id", "ancestors").From("dept").
+					Where(dbx.HashExp{"id": w.id}).
+					Row(&got.parent, &got.ancestors)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != w {
+					t.Fatalf("expected %+v, got %+v", w, got)
+				}
+			}
+
+			if s.dryRun {
+				return
+			}
+			// a second run has nothing left to repair
+			again, err := rebuildDeptAncestors(app, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Changed != 0 || len(again.Broken) != 0 {
+				t.Fatalf("expected no changes on second run, got %+v", again)
+			}
+		})
+	}
+}
@@ -10,8 +10,11 @@ import (
 
 	"pocketbase-ruoyi/tools"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -47,25 +50,41 @@ func RegisterSystemImportExport(app *pocketbase.PocketBase) {
 			}
 			offset := (page - 1) * limit
 
-			records, err := e.App.FindRecordsByFilter(coll.Name, filter, sort, limit, offset)
+			if err := tools.CheckClientFilterFields(e, filter, sort); err != nil {
+				return err
+			}
+
+			// 与列表接口一致：集合 listRule + 租户与角色数据范围，仅导出用户可列出的记录
+			query, resolver, err := tools.ScopedRecordQuery(e, coll)
+			if err != nil {
+				return err
+			}
+
+			records, err := findScopedRecords(query, resolver, filter, sort, limit, offset)
 			if err != nil {
-				return e.InternalServerError("查询记录失败", err)
+				return e.BadRequestError("查询记录失败", err)
+			}
+
+			// 与列表接口一致地处理隐藏字段、邮箱可见性等
+			if err := apis.EnrichRecords(e, records); err != nil {
+				return e.InternalServerError("处理记录失败", err)
 			}
 
 			f := excelize.NewFile()
 			sheet := f.GetSheetName(0)
 
 			// 收集字段名（使用集合 schema 中的字段 + id + 创建/更新时间）
-			fieldNames := collectExportFields(coll)
+			fieldNames := collectExportFields(coll, e.HasSuperuserAuth())
 			for i, name := range fieldNames {
 				cell, _ := excelize.CoordinatesToCellName(i+1, 1)
 				_ = f.SetCellValue(sheet, cell, name)
 			}
 
 			for rIdx, rec := range records {
+				data := rec.PublicExport()
 				for cIdx, name := range fieldNames {
 					cell, _ := excelize.CoordinatesToCellName(cIdx+1, rIdx+2)
-					_ = f.SetCellValue(sheet, cell, rec.Get(name))
+					_ = f.SetCellValue(sheet, cell, data[name])
 				}
 			}
 
@@ -117,6 +136,10 @@ func RegisterSystemImportExport(app *pocketbase.PocketBase) {
 			}
 
 			validFields := buildFieldSet(coll)
+			// 导入记录归属当前租户（超级管理员可在 Excel 中指定 tenant_id）
+			tenantField := tools.GetDataScopeFields(coll).Tenant
+			tenantID := tools.GetUserTenant(e)
+			superuser := isSuperuserByEvent(e)
 			imported := 0
 			failed := 0
 			errs := []string{}
@@ -134,6 +157,13 @@ func RegisterSystemImportExport(app *pocketbase.PocketBase) {
 					}
 					rec.Set(h, val)
 				}
+				if tenantID != "" {
+					if superuser {
+						tenantField.AssignIfEmpty(rec, tenantID)
+					} else {
+						tenantField.Assign(rec, tenantID)
+					}
+				}
 				if err := e.App.Save(rec); err != nil {
 					failed++
 					if len(errs) < 10 { // 只保留前 10 条错误信息
@@ -155,6 +185,42 @@ func RegisterSystemImportExport(app *pocketbase.PocketBase) {
 	})
 }
 
+// findScopedRecords 在 tools.ScopedRecordQuery 返回的查询上追加客户端 filter/sort 与分页并执行
+func findScopedRecords(q *dbx.SelectQuery, resolver *core.RecordFieldResolver, filter, sort string, limit, offset int) ([]*core.Record, error) {
+	if filter != "" {
+		expr, err := search.FilterData(filter).BuildExpr(resolver)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter expression: %w", err)
+		}
+		q.AndWhere(expr)
+	}
+	if sort != "" {
+		for _, sortField := range search.ParseSortFromString(sort) {
+			expr, err := sortField.BuildExpr(resolver)
+			if err != nil {
+				return nil, err
+			}
+			if expr != "" {
+				q.AndOrderBy(expr)
+			}
+		}
+	}
+	resolver.UpdateQuery(q)
+
+	if offset > 0 {
+		q.Offset(int64(offset))
+	}
+	if limit > 0 {
+		q.Limit(int64(limit))
+	}
+
+	records := []*core.Record{}
+	if err := q.All(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
 func parsePositiveInt(s string, def int) int {
 	i, err := strconv.Atoi(s)
 	if err != nil || i <= 0 {
@@ -163,13 +229,13 @@ func parsePositiveInt(s string, def int) int {
 	return i
 }
 
-// collectExportFields 组装导出字段
-func collectExportFields(coll *core.Collection) []string {
+// collectExportFields 组装导出字段；非超级用户不导出隐藏字段（与列表接口一致）
+func collectExportFields(coll *core.Collection, includeHidden bool) []string {
 	seen := map[string]struct{}{}
 	fields := []string{}
 	for _, f := range coll.Fields {
 		name := f.GetName()
-		if name == "" {
+		if name == "" || (f.GetHidden() && !includeHidden) {
 			continue
 		}
 		if _, ok := seen[name]; !ok {
@@ -0,0 +1,102 @@
+package menu
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// routerCacheTTL 单个用户路由树的缓存时间；版本号变化时旧缓存自然失效
+const routerCacheTTL = 30 * time.Minute
+
+// routerVersion 路由树版本号：menu、role_menu、user_role 变更时递增。
+// 以启动时间为初值，服务重启后前端轮询到的版本同样会变化。
+var routerVersion atomic.Int64
+
+func init() {
+	routerVersion.Store(time.Now().UnixMilli())
+}
+
+// RouterVersion 返回当前路由树版本号
+func RouterVersion() int64 {
+	return routerVersion.Load()
+}
+
+// InvalidateRouters 使所有用户的路由树缓存失效（不经过记录钩子直接修改菜单或授权数据后调用）
+func InvalidateRouters() {
+	routerVersion.Add(1)
+}
+
+// routerCacheEntry 缓存的 getRouters 响应
+type routerCacheEntry struct {
+	etag string
+	body []byte
+}
+
+// registerRouterCache 注册路由树缓存失效钩子
+func registerRouterCache(app *pocketbase.PocketBase) {
+	invalidate := func(e *core.RecordEvent) error {
+		InvalidateRouters()
+		return e.Next()
+	}
+	for _, name := range []string{"menu", "role_menu", "user_role"} {
+		app.OnRecordAfterCreateSuccess(name).BindFunc(invalidate)
+		app.OnRecordAfterUpdateSuccess(name).BindFunc(invalidate)
+		app.OnRecordAfterDeleteSuccess(name).BindFunc(invalidate)
+	}
+}
+
+// cachedRouters 返回用户当前版本、当前语言的路由树响应（data 旁附带 version），未缓存时构建并缓存
+func cachedRouters(app *pocketbase.PocketBase, e *core.RequestEvent, userID, locale string, version int64) (*routerCacheEntry, error) {
+	key := fmt.Sprintf("routers:%d:%s:%s", version, userID, locale)
+	if v, ok := tools.CacheGetValue(key); ok {
+		if entry, ok := v.(*routerCacheEntry); ok {
+			return entry, nil
+		}
+	}
+
+	routers, err := loadRouters(app, e, userID, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"code":    http.StatusOK,
+		"msg":     "操作成功",
+		"data":    routers,
+		"version": strconv.FormatInt(version, 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(body)
+	entry := &routerCacheEntry{
+		etag: `"` + strconv.FormatInt(version, 10) + "-" + hex.EncodeToString(sum[:8]) + `"`,
+		body: body,
+	}
+	tools.CacheSetValue(key, entry, routerCacheTTL)
+	return entry, nil
+}
+
+// etagMatch 判断 If-None-Match 是否包含指定 ETag（忽略弱校验前缀）
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package menu
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// menuLocaleField 用户的语言偏好字段（users.language），优先于 Accept-Language
+const menuLocaleField = "language"
+
+// menuLocale 确定菜单名称使用的语言：用户语言偏好 > Accept-Language，均未设置时返回空（使用 menu_name）
+func menuLocale(e *core.RequestEvent) string {
+	if e.Auth != nil {
+		if l := normalizeLocale(e.Auth.GetString(menuLocaleField)); l != "" {
+			return l
+		}
+	}
+
+	// 取权重最高的语言；前端发送的是 zh_CN/en_US 形式
+	best, bestQ := "", -1.0
+	for _, part := range strings.Split(e.Request.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = normalizeLocale(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+// normalizeLocale 统一语言标签格式，如 zh_cn -> zh-CN、EN -> en
+func normalizeLocale(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if tag == "" {
+		return ""
+	}
+	lang, region, ok := strings.Cut(tag, "-")
+	if !ok {
+		return strings.ToLower(lang)
+	}
+	return strings.ToLower(lang) + "-" + strings.ToUpper(region)
+}
+
+// localizedName 返回菜单在指定语言下的名称：先精确匹配，再按主语言匹配（en 匹配 en-US），否则使用 menu_name
+func (m *Menu) localizedName(locale string) string {
+	names := m.i18nNames()
+	if locale == "" || len(names) == 0 {
+		return m.MenuName
+	}
+
+	// 同一主语言有多个地区时，优先不带地区的标签，其次按标签排序取第一个，保证结果稳定
+	lang, _, _ := strings.Cut(locale, "-")
+	fallback, fallbackTag := "", ""
+	for tag, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		tag = normalizeLocale(tag)
+		if tag == locale {
+			return name
+		}
+		l, _, _ := strings.Cut(tag, "-")
+		if l != lang || fallbackTag == lang {
+			continue
+		}
+		if fallback == "" || tag == lang || tag < fallbackTag {
+			fallback, fallbackTag = name, tag
+		}
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return m.MenuName
+}
+
+// i18nNames 解析 menu_name_i18n，未配置或格式错误时返回 nil
+func (m *Menu) i18nNames() map[string]string {
+	if len(m.MenuNameI18n) == 0 {
+		return nil
+	}
+	names := map[string]string{}
+	if err := json.Unmarshal(m.MenuNameI18n, &names); err != nil {
+		return nil
+	}
+	return names
+}
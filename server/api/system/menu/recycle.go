@@ -0,0 +1,402 @@
+package menu
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// menuRecycleCollection 菜单回收站集合
+const menuRecycleCollection = "menu_recycle"
+
+// menuRef 影响报告中的菜单/角色/套餐引用
+type menuRef struct {
+	ID   string `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+}
+
+// menuDeleteImpact 删除菜单的影响范围
+type menuDeleteImpact struct {
+	MenuID   string    `json:"menu_id"`
+	MenuName string    `json:"menu_name"`
+	Children []menuRef `json:"children"` // 全部下级菜单（含按钮）
+	Roles    []menuRef `json:"roles"`    // 分配了其中任一菜单的角色
+	Packages []menuRef `json:"packages"` // 包含其中任一菜单的租户套餐
+}
+
+// blocked 存在下级菜单或已被角色、租户套餐引用时，不允许直接删除
+func (i *menuDeleteImpact) blocked() bool {
+	return len(i.Children) > 0 || len(i.Roles) > 0 || len(i.Packages) > 0
+}
+
+func (i *menuDeleteImpact) message() string {
+	parts := []string{}
+	if n := len(i.Children); n > 0 {
+		parts = append(parts, fmt.Sprintf("下级菜单 %d 个", n))
+	}
+	if n := len(i.Roles); n > 0 {
+		parts = append(parts, fmt.Sprintf("已分配角色 %d 个", n))
+	}
+	if n := len(i.Packages); n > 0 {
+		parts = append(parts, fmt.Sprintf("租户套餐 %d 个", n))
+	}
+	return fmt.Sprintf("菜单「%s」存在%s，不允许删除；确认后可级联删除到回收站", i.MenuName, strings.Join(parts, "、"))
+}
+
+// menuSnapshot 回收站中保存的菜单子树及其关联，用于恢复
+type menuSnapshot struct {
+	Menus     []map[string]any    `json:"menus"`      // 按上级在前的顺序保存
+	RoleMenus []menuSnapshotLink  `json:"role_menus"` // 角色与菜单的关联
+	Packages  map[string][]string `json:"packages"`   // 租户套餐ID -> 被移除的菜单ID
+}
+
+type menuSnapshotLink struct {
+	Role string `db:"role" json:"role"`
+	Menu string `db:"menu" json:"menu"`
+}
+
+// menuRestoreReport 回收站恢复结果
+type menuRestoreReport struct {
+	Menus    int `json:"menus"`
+	Roles    int `json:"roles"`
+	Packages int `json:"packages"`
+}
+
+// registerMenuRecycle 注册菜单安全删除与回收站：
+//   - 删除菜单时，存在下级菜单或已分配给角色、租户套餐则拒绝删除；
+//     请求带 ?cascade=true 时将整个子树及其角色、套餐关联移入回收站后再删除；
+//   - GET /api/system/menu/deleteImpact/{menuId} 查看删除影响范围；
+//   - GET /api/system/menu/recycle/list、POST /api/system/menu/recycle/{id}/restore、
+//     DELETE /api/system/menu/recycle/{id} 回收站列表、恢复与彻底删除（仅超级管理员）。
+func registerMenuRecycle(app *pocketbase.PocketBase) {
+	app.OnRecordDeleteRequest("menu").BindFunc(guardMenuDelete)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/system/menu/deleteImpact/{menuId}", func(e *core.RequestEvent) error {
+			if !isMenuSuperuser(app, e) {
+				return e.ForbiddenError("仅超级管理员可删除菜单", nil)
+			}
+			menu, err := e.App.FindRecordById("menu", e.Request.PathValue("menuId"))
+			if err != nil {
+				return e.NotFoundError("菜单不存在", err)
+			}
+			impact, err := findMenuDeleteImpact(e.App, menu)
+			if err != nil {
+				return e.InternalServerError("统计删除影响失败", err)
+			}
+			return tools.JSONSuccess(e, impact)
+		}).Bind(apis.RequireAuth())
+
+		se.Router.GET("/api/system/menu/recycle/list", func(e *core.RequestEvent) error {
+			if !isMenuSuperuser(app, e) {
+				return e.ForbiddenError("仅超级管理员可查看菜单回收站", nil)
+			}
+			records, err := e.App.FindRecordsByFilter(menuRecycleCollection, "", "-create_time", 0, 0)
+			if err != nil {
+				return e.InternalServerError("查询菜单回收站失败", err)
+			}
+			for _, r := range records {
+				r.Hide("snapshot")
+			}
+			return tools.JSONSuccess(e, records)
+		}).Bind(apis.RequireAuth())
+
+		se.Router.POST("/api/system/menu/recycle/{id}/restore", func(e *core.RequestEvent) error {
+			if !isMenuSuperuser(app, e) {
+				return e.ForbiddenError("仅超级管理员可恢复菜单", nil)
+			}
+
+			var report *menuRestoreReport
+			err := e.App.RunInTransaction(func(txApp core.App) error {
+				rec, err := txApp.FindRecordById(menuRecycleCollection, e.Request.PathValue("id"))
+				if err != nil {
+					return apis.NewNotFoundError("回收站记录不存在", err)
+				}
+				report, err = restoreMenuSnapshot(txApp, rec)
+				return err
+			})
+			if err != nil {
+				var apiErr *router.ApiError
+				if errors.As(err, &apiErr) {
+					return apiErr
+				}
+				return e.BadRequestError("恢复菜单失败："+err.Error(), nil)
+			}
+			return tools.JSONSuccess(e, report)
+		}).Bind(apis.RequireAuth())
+
+		se.Router.DELETE("/api/system/menu/recycle/{id}", func(e *core.RequestEvent) error {
+			if !isMenuSuperuser(app, e) {
+				return e.ForbiddenError("仅超级管理员可清除菜单回收站", nil)
+			}
+			rec, err := e.App.FindRecordById(menuRecycleCollection, e.Request.PathValue("id"))
+			if err != nil {
+				return e.NotFoundError("回收站记录不存在", err)
+			}
+			if err := e.App.Delete(rec); err != nil {
+				return e.InternalServerError("清除回收站记录失败", err)
+			}
+			return tools.JSONSuccess(e, nil)
+		}).Bind(apis.RequireAuth())
+
+		return se.Next()
+	})
+}
+
+// guardMenuDelete 删除请求的保护：有影响时默认拒绝，cascade=true 时先移入回收站，
+// 并与菜单删除在同一事务中完成
+func guardMenuDelete(e *core.RecordRequestEvent) error {
+	impact, err := findMenuDeleteImpact(e.App, e.Record)
+	if err != nil {
+		return e.InternalServerError("统计删除影响失败", err)
+	}
+	if !impact.blocked() {
+		return e.Next()
+	}
+	if e.Request.URL.Query().Get("cascade") != "true" {
+		return e.BadRequestError(impact.message(), nil)
+	}
+
+	operator := ""
+	if e.Auth != nil {
+		operator = e.Auth.Id
+	}
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		original := e.App
+		e.App = txApp
+		defer func() { e.App = original }()
+
+		if err := recycleMenuTree(txApp, e.Record, operator); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+}
+
+// menuSubtree 返回菜单及其全部下级菜单，按上级在前的顺序排列（parent_id 存在循环时每个菜单只出现一次）
+func menuSubtree(app core.App, root *core.Record) ([]*core.Record, error) {
+	out := []*core.Record{root}
+	seen := map[string]struct{}{root.Id: {}}
+	for i := 0; i < len(out); i++ {
+		children, err := app.FindRecordsByFilter("menu", "parent_id={:pid}", "order_num", 0, 0, dbx.Params{"pid": out[i].Id})
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if _, ok := seen[child.Id]; ok {
+				continue
+			}
+			seen[child.Id] = struct{}{}
+			out = append(out, child)
+		}
+	}
+	return out, nil
+}
+
+// findMenuDeleteImpact 统计删除菜单会影响到的下级菜单、角色与租户套餐
+func findMenuDeleteImpact(app core.App, menu *core.Record) (*menuDeleteImpact, error) {
+	impact := &menuDeleteImpact{
+		MenuID:   menu.Id,
+		MenuName: menu.GetString("menu_name"),
+		Children: []menuRef{},
+		Roles:    []menuRef{},
+		Packages: []menuRef{},
+	}
+
+	subtree, err := menuSubtree(app, menu)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(subtree))
+	for _, m := range subtree {
+		ids = append(ids, m.Id)
+		if m.Id != menu.Id {
+			impact.Children = append(impact.Children, menuRef{ID: m.Id, Name: m.GetString("menu_name")})
+		}
+	}
+
+	err = app.DB().Select("r.id", "r.role_name as name").Distinct(true).From("role as r").
+		InnerJoin("role_menu as rm", dbx.NewExp("rm.role = r.id")).
+		Where(dbx.In("rm.menu", toAnySlice(ids)...)).
+		All(&impact.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	packages, err := menuPackages(app, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range packages {
+		impact.Packages = append(impact.Packages, menuRef{ID: pkg.Id, Name: pkg.GetString("package_name")})
+	}
+
+	return impact, nil
+}
+
+// menuPackages 查找包含任一菜单的租户套餐
+func menuPackages(app core.App, menuIDs []string) ([]*core.Record, error) {
+	all, err := app.FindAllRecords("tenant_package")
+	if err != nil {
+		return nil, err
+	}
+	set := map[string]struct{}{}
+	for _, id := range menuIDs {
+		set[id] = struct{}{}
+	}
+
+	out := []*core.Record{}
+	for _, pkg := range all {
+		for _, id := range pkg.GetStringSlice("menu_ids") {
+			if _, ok := set[id]; ok {
+				out = append(out, pkg)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// recycleMenuTree 将菜单子树及其角色、套餐关联保存到回收站，并删除下级菜单（根菜单由调用方删除）
+func recycleMenuTree(app core.App, root *core.Record, operator string) error {
+	subtree, err := menuSubtree(app, root)
+	if err != nil {
+		return err
+	}
+
+	snap := menuSnapshot{
+		Menus:     make([]map[string]any, 0, len(subtree)),
+		RoleMenus: []menuSnapshotLink{},
+		Packages:  map[string][]string{},
+	}
+	ids := make([]string, 0, len(subtree))
+	set := map[string]struct{}{}
+	for _, m := range subtree {
+		snap.Menus = append(snap.Menus, m.FieldsData())
+		ids = append(ids, m.Id)
+		set[m.Id] = struct{}{}
+	}
+
+	err = app.DB().Select("role", "menu").From("role_menu").
+		Where(dbx.In("menu", toAnySlice(ids)...)).
+		All(&snap.RoleMenus)
+	if err != nil {
+		return err
+	}
+
+	packages, err := menuPackages(app, ids)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range packages {
+		for _, id := range pkg.GetStringSlice("menu_ids") {
+			if _, ok := set[id]; ok {
+				snap.Packages[pkg.Id] = append(snap.Packages[pkg.Id], id)
+			}
+		}
+	}
+
+	coll, err := app.FindCachedCollectionByNameOrId(menuRecycleCollection)
+	if err != nil {
+		return err
+	}
+	rec := core.NewRecord(coll)
+	rec.Set("menu_id", root.Id)
+	rec.Set("menu_name", root.GetString("menu_name"))
+	rec.Set("item_count", len(subtree))
+	rec.Set("snapshot", snap)
+	rec.Set("delete_by", operator)
+	if err := app.Save(rec); err != nil {
+		return err
+	}
+
+	// 由下往上删除下级菜单；角色关联随菜单级联删除，套餐中的菜单引用由删除时自动移除
+	for i := len(subtree) - 1; i > 0; i-- {
+		if err := app.Delete(subtree[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreMenuSnapshot 从回收站恢复菜单子树（保留原ID），并恢复仍存在的角色与套餐关联
+func restoreMenuSnapshot(app core.App, rec *core.Record) (*menuRestoreReport, error) {
+	snap := menuSnapshot{}
+	if err := rec.UnmarshalJSONField("snapshot", &snap); err != nil {
+		return nil, err
+	}
+	report := &menuRestoreReport{}
+
+	coll, err := app.FindCachedCollectionByNameOrId("menu")
+	if err != nil {
+		return nil, err
+	}
+
+	for i, data := range snap.Menus {
+		id := fmt.Sprint(data["id"])
+		if _, err := app.FindRecordById("menu", id); err == nil {
+			return nil, fmt.Errorf("菜单ID %s 已存在", id)
+		}
+
+		m := core.NewRecord(coll)
+		m.Load(data)
+		// 原上级菜单已不存在时恢复到根目录
+		if i == 0 {
+			if pid := m.GetString("parent_id"); pid != "" && pid != "0" {
+				if _, err := app.FindRecordById("menu", pid); err != nil {
+					m.Set("parent_id", "0")
+				}
+			}
+		}
+		// 原ID可能是 RuoYi 的数字ID，与种子导入相同，仅放宽 id 的长度校验
+		if err := saveSeedMenu(app, m); err != nil {
+			return nil, fmt.Errorf("恢复菜单 %s 失败：%w", m.GetString("menu_name"), err)
+		}
+		report.Menus++
+	}
+
+	roleColl, err := app.FindCachedCollectionByNameOrId("role_menu")
+	if err != nil {
+		return nil, err
+	}
+	roles := map[string]struct{}{}
+	for _, link := range snap.RoleMenus {
+		if _, err := app.FindRecordById("role", link.Role); err != nil {
+			continue
+		}
+		rm := core.NewRecord(roleColl)
+		rm.Set("role", link.Role)
+		rm.Set("menu", link.Menu)
+		if err := app.Save(rm); err != nil {
+			return nil, err
+		}
+		roles[link.Role] = struct{}{}
+	}
+	report.Roles = len(roles)
+
+	for pkgID, menuIDs := range snap.Packages {
+		pkg, err := app.FindRecordById("tenant_package", pkgID)
+		if err != nil {
+			continue
+		}
+		pkg.Set("menu_ids+", menuIDs)
+		if err := app.Save(pkg); err != nil {
+			return nil, err
+		}
+		report.Packages++
+	}
+
+	if err := app.Delete(rec); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -1,6 +1,9 @@
 package menu
 
 import (
+	"net/http"
+	"strconv"
+
 	"pocketbase-ruoyi/tools"
 
 	"github.com/pocketbase/dbx"
@@ -8,10 +11,19 @@ import (
 	"github.com/pocketbase/pocketbase/core"
 )
 
-// RegisterSystemMenu 注册 /api/system/menu/getRouters
+// RouterVersionHeader 路由树版本号响应头
+const RouterVersionHeader = "X-Router-Version"
+
+// RegisterSystemMenu 注册 /api/system/menu/getRouters、菜单下拉树、菜单种子导入导出、菜单回收站与集合菜单生成接口
 func RegisterSystemMenu(app *pocketbase.PocketBase) {
 	app.OnRecordDeleteExecute("menu").BindFunc(syncDeleteRoleMenu)
 	app.OnRecordAfterDeleteSuccess("menu").BindFunc(syncMenuDeleteAfter)
+	registerRouterCache(app)
+	registerMenuTreeSelect(app)
+	registerMenuSeed(app)
+	registerMenuRecycle(app)
+	registerMenuScaffold(app)
+
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 		se.Router.GET("/api/system/menu/getRouters", func(e *core.RequestEvent) error {
 			ri, err := e.RequestInfo()
@@ -22,41 +34,66 @@ func RegisterSystemMenu(app *pocketbase.PocketBase) {
 				return e.UnauthorizedError("未登录或无权限", nil)
 			}
 
-			menus := []Menu{}
-
-			if tools.IsRoleSuperuser(app, ri.Auth.Id) {
-				q := e.App.DB().Select("*").From("menu").
-					Where(dbx.In("menu_type", "M", "C")).
-					AndWhere(dbx.HashExp{"status": "0"}).
-					OrderBy("parent_id ASC", "order_num ASC")
-				if err := q.All(&menus); err != nil {
-					return e.InternalServerError("查询菜单失败", err)
-				}
-			} else {
-				uid := ri.Auth.Id
-
-				q := e.App.DB().
-					Select("m.*").
-					From("menu as m").
-					InnerJoin("role_menu as rm", dbx.NewExp("rm.menu = m.id")).
-					InnerJoin("user_role as ur", dbx.NewExp("ur.role = rm.role")).
-					Where(dbx.HashExp{"ur.user": uid}).
-					AndWhere(dbx.In("m.menu_type", "M", "C")).
-					AndWhere(dbx.HashExp{"m.status": "0"}).
-					OrderBy("m.parent_id ASC", "m.order_num ASC")
-
-				if err := q.All(&menus); err != nil {
-					return e.InternalServerError("查询用户菜单失败", err)
-				}
+			version := RouterVersion()
+			entry, err := cachedRouters(app, e, ri.Auth.Id, menuLocale(e), version)
+			if err != nil {
+				return e.InternalServerError("查询菜单失败", err)
 			}
 
-			menus = uniqueMenus(menus)
+			h := e.Response.Header()
+			h.Set("ETag", entry.etag)
+			h.Set("Cache-Control", "private, no-cache")
+			h.Set("Vary", "Accept-Language")
+			h.Set(RouterVersionHeader, strconv.FormatInt(version, 10))
+			if etagMatch(e.Request.Header.Get("If-None-Match"), entry.etag) {
+				return e.NoContent(http.StatusNotModified)
+			}
 
-			tree := buildMenuTree(menus)
-			routers := buildRouters(tree)
+			return e.Blob(http.StatusOK, "application/json", entry.body)
+		})
 
-			return tools.JSONSuccess(e, routers)
+		// 前端轮询路由树版本号，变化后重新拉取菜单
+		se.Router.GET("/api/system/menu/getRouters/version", func(e *core.RequestEvent) error {
+			if e.Auth == nil {
+				return e.UnauthorizedError("未登录或无权限", nil)
+			}
+			return tools.JSONSuccess(e, map[string]any{
+				"version": strconv.FormatInt(RouterVersion(), 10),
+			})
 		})
 		return se.Next()
 	})
 }
+
+// loadRouters 查询用户可见的目录与菜单并转换为前端路由树（超级管理员返回全部），菜单名称按 locale 翻译
+func loadRouters(app *pocketbase.PocketBase, e *core.RequestEvent, userID, locale string) ([]Router, error) {
+	menus := []Menu{}
+
+	if tools.IsRoleSuperuser(app, userID) {
+		q := e.App.DB().Select("*").From("menu").
+			Where(dbx.In("menu_type", "M", "C")).
+			AndWhere(dbx.HashExp{"status": "0"}).
+			OrderBy("parent_id ASC", "order_num ASC")
+		if err := q.All(&menus); err != nil {
+			return nil, err
+		}
+	} else {
+		q := e.App.DB().
+			Select("m.*").
+			From("menu as m").
+			InnerJoin("role_menu as rm", dbx.NewExp("rm.menu = m.id")).
+			InnerJoin("user_role as ur", dbx.NewExp("ur.role = rm.role")).
+			Where(dbx.HashExp{"ur.user": userID}).
+			AndWhere(dbx.In("m.menu_type", "M", "C")).
+			AndWhere(dbx.HashExp{"m.status": "0"}).
+			OrderBy("m.parent_id ASC", "m.order_num ASC")
+		if err := q.All(&menus); err != nil {
+			return nil, err
+		}
+	}
+
+	menus = uniqueMenus(menus)
+
+	tree := buildMenuTree(menus)
+	return buildRouters(tree, locale), nil
+}
@@ -1,85 +1,207 @@
 package menu
 
-import (
-	"fmt"
-	"strings"
+import "strings"
+
+// 前端约定的特殊组件
+const (
+	componentLayout     = "Layout"
+	componentParentView = "ParentView"
+	componentInnerLink  = "InnerLink"
 )
 
-func buildRouters(nodes []*MenuNode) []Router {
+// routeBuilder 构建前端路由树：菜单名称按语言翻译，路由名称由完整路径生成（稳定的 ASCII 名称）
+type routeBuilder struct {
+	locale string
+	names  map[string]struct{}
+}
+
+func buildRouters(nodes []*MenuNode, locale string) []Router {
+	b := &routeBuilder{locale: locale, names: map[string]struct{}{}}
+	return b.routes(nodes, "")
+}
+
+func (b *routeBuilder) routes(nodes []*MenuNode, parentPath string) []Router {
 	out := make([]Router, 0, len(nodes))
 	for _, n := range nodes {
-		out = append(out, nodeToRoute(n))
+		out = append(out, b.nodeToRoute(n, parentPath))
 	}
 	return out
 }
 
-func nodeToRoute(n *MenuNode) Router {
-	hidden := n.Visible == "1"
-	noCache := n.IsCache == "1"
+// nodeToRoute 与 RuoYi 一致的路由转换：
+//   - 目录：子路由 + alwaysShow/noRedirect；
+//   - 一级菜单（非外链）：外层 Layout（path 为 /），菜单本身作为唯一子路由；
+//   - 一级内链（is_frame=1 且为 http(s) 地址）：外层 Layout，子路由为 InnerLink（iframe 内嵌）；
+//   - 外链（is_frame=0 且为 http(s) 地址）：path 为链接地址，由前端新窗口打开。
+func (b *routeBuilder) nodeToRoute(n *MenuNode, parentPath string) Router {
+	fullPath := joinRoutePath(parentPath, n)
+	meta := b.buildMeta(n)
 
-	var linkPtr *string
-	if n.IsFrame == "1" && strings.TrimSpace(n.Path) != "" {
-		l := n.Path
-		linkPtr = &l
+	switch {
+	case len(n.Children) > 0:
+		r := b.newRouter(n, derivePath(n), deriveComponent(n), meta, fullPath)
+		r.AlwaysShow = n.MenuType == "M"
+		r.Redirect = "noRedirect"
+		r.Children = b.routes(n.Children, fullPath)
+		return r
+	case isMenuFrame(n):
+		// 路由名称留给子路由，避免重名
+		r := newRouter(n, derivePath(n), deriveComponent(n), meta, "")
+		child := b.newRouter(n, strings.TrimPrefix(strings.TrimSpace(n.Path), "/"), n.Component, meta, fullPath)
+		child.Hidden = false
+		r.Children = []Router{child}
+		return r
+	case n.ParentID == "0" && isInnerLink(n):
+		r := newRouter(n, "/", deriveComponent(n), RouteMeta{Title: meta.Title, Icon: n.Icon, Breadcrumb: true}, "")
+		child := b.newRouter(n, innerLinkPath(n.Path), componentInnerLink, meta, fullPath)
+		child.Hidden = false
+		r.Children = []Router{child}
+		return r
 	}
 
-	var activeMenu *string = nil
+	return b.newRouter(n, derivePath(n), deriveComponent(n), meta, fullPath)
+}
 
-	r := Router{
-		AlwaysShow: len(n.Children) > 0,
-		Component:  deriveComponent(n),
-		Hidden:     hidden,
-		Meta: RouteMeta{
-			Title:   n.MenuName,
-			Icon:    n.Icon,
-			NoCache: noCache,
-			Link:    linkPtr,
-		},
-		ActiveMenu: activeMenu,
-		Icon:       n.Icon,
-		Link:       linkPtr,
-		NoCache:    noCache,
-		Title:      n.MenuName,
-		Name:       deriveName(n.MenuName, n.ID),
-		Path:       derivePath(n),
+// newRouter 构建带路由名称的路由，名称重复时追加菜单ID
+func (b *routeBuilder) newRouter(n *MenuNode, path, component string, meta RouteMeta, fullPath string) Router {
+	name := routeName(fullPath, n.ID)
+	if _, dup := b.names[name]; dup {
+		name += n.ID
 	}
+	b.names[name] = struct{}{}
+	return newRouter(n, path, component, meta, name)
+}
 
-	if len(n.Children) > 0 {
-		r.Redirect = "noRedirect"
-		r.Children = buildRouters(n.Children)
+// newRouter 构建路由，顶层的 title/icon 等字段与 meta 保持一致（兼容旧前端）
+func newRouter(n *MenuNode, path, component string, meta RouteMeta, name string) Router {
+	r := Router{
+		Component: component,
+		Hidden:    n.Visible == "1",
+		Meta:      meta,
+		Icon:      meta.Icon,
+		Link:      meta.Link,
+		NoCache:   meta.NoCache,
+		Title:     meta.Title,
+		Name:      name,
+		Path:      path,
+		Query:     meta.Query,
+	}
+	if meta.ActiveMenu != "" {
+		activeMenu := meta.ActiveMenu
+		r.ActiveMenu = &activeMenu
 	}
-
 	return r
 }
 
-func deriveComponent(n *MenuNode) string {
-	if n.MenuType == "M" {
-		if n.ParentID == "0" {
-			return "Layout"
-		}
-		return "ParentView"
+// buildMeta 由菜单生成路由元信息
+func (b *routeBuilder) buildMeta(n *MenuNode) RouteMeta {
+	meta := RouteMeta{
+		Title:      n.localizedName(b.locale),
+		Icon:       n.Icon,
+		NoCache:    n.IsCache == "1",
+		Query:      strings.TrimSpace(n.QueryParam),
+		ActiveMenu: strings.TrimSpace(n.ActiveMenu),
+		Affix:      n.Affix == "0",
+		Breadcrumb: n.Breadcrumb != "1",
 	}
-	if strings.TrimSpace(n.Component) != "" {
-		return n.Component
+	if isHTTP(n.Path) {
+		link := strings.TrimSpace(n.Path)
+		meta.Link = &link
+	}
+	return meta
+}
+
+func deriveComponent(n *MenuNode) string {
+	component := strings.TrimSpace(n.Component)
+	switch {
+	case component != "" && !isMenuFrame(n):
+		return component
+	case n.ParentID != "0" && isInnerLink(n):
+		return componentInnerLink
+	case n.MenuType == "M" && n.ParentID != "0":
+		return componentParentView
+	case n.MenuType == "C" && n.ParentID != "0" && !isHTTP(n.Path):
+		// 未配置组件的子菜单按中间层处理
+		return componentParentView
 	}
-	return "ParentView"
+	return componentLayout
 }
 
 func derivePath(n *MenuNode) string {
 	p := strings.TrimSpace(n.Path)
-	if n.ParentID == "0" {
-		if p == "" || p[0] != '/' {
-			return "/" + strings.TrimPrefix(p, "/")
-		}
+	switch {
+	case n.ParentID != "0" && isInnerLink(n):
+		return innerLinkPath(p)
+	case isMenuFrame(n):
+		return "/"
+	case isHTTP(p):
 		return p
+	case n.ParentID == "0":
+		return "/" + strings.TrimPrefix(p, "/")
 	}
 	return strings.TrimPrefix(p, "/")
 }
 
-func deriveName(menuName string, id string) string {
-	base := strings.TrimSpace(menuName)
-	if base == "" {
-		base = "Menu"
+// joinRoutePath 拼接菜单的完整路由地址，链接地址按内链规则转换为路径
+func joinRoutePath(parentPath string, n *MenuNode) string {
+	p := strings.TrimSpace(n.Path)
+	if isHTTP(p) {
+		return innerLinkPath(p)
+	}
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return parentPath
+	}
+	return strings.TrimPrefix(parentPath+"/"+p, "/")
+}
+
+// routeName 由完整路由地址生成路由名称，如 system/user-auth/:id -> SystemUserAuthId；
+// 路径中没有可用的 ASCII 字符时使用 Menu+菜单ID
+func routeName(fullPath, id string) string {
+	var sb strings.Builder
+	upper := true
+	for _, c := range fullPath {
+		switch {
+		case c >= 'a' && c <= 'z':
+			if upper {
+				c -= 'a' - 'A'
+			}
+			sb.WriteRune(c)
+			upper = false
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			sb.WriteRune(c)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	name := sb.String()
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "Menu" + name
 	}
-	return fmt.Sprintf("%s%s", base, id)
+	if name == "Menu" {
+		name += id
+	}
+	return name
+}
+
+// isMenuFrame 一级菜单且不是外链（需要外层 Layout 包裹）
+func isMenuFrame(n *MenuNode) bool {
+	return n.ParentID == "0" && n.MenuType == "C" && n.IsFrame != "0" && !isHTTP(n.Path)
+}
+
+// isInnerLink 内链：链接地址但不是外链，使用 iframe 在系统内打开
+func isInnerLink(n *MenuNode) bool {
+	return n.IsFrame != "0" && isHTTP(n.Path)
+}
+
+func isHTTP(p string) bool {
+	p = strings.TrimSpace(p)
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// innerLinkPath 将内链地址转换为路由路径，如 https://www.a.com:8080/x -> a/com/8080/x
+func innerLinkPath(p string) string {
+	return strings.NewReplacer("http://", "", "https://", "", "www.", "", ".", "/", ":", "/").
+		Replace(strings.TrimSpace(p))
 }
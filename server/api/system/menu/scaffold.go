@@ -0,0 +1,327 @@
+package menu
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// scaffoldActions 按钮权限动作，与 RegisterRBAC 按集合路由推导的权限标识 {collection}:{action} 一致
+var scaffoldActions = []struct {
+	Action string
+	Label  string
+}{
+	{"query", "查询"},
+	{"add", "新增"},
+	{"edit", "修改"},
+	{"remove", "删除"},
+	{"export", "导出"},
+	{"import", "导入"},
+}
+
+// menuScaffoldRequest 生成集合菜单的请求参数，未填写的字段按集合名称生成
+type menuScaffoldRequest struct {
+	Collection string   `json:"collection"`
+	ParentID   string   `json:"parent_id"`
+	MenuName   string   `json:"menu_name"`
+	Path       string   `json:"path"`
+	Component  string   `json:"component"`
+	Icon       string   `json:"icon"`
+	OrderNum   int      `json:"order_num"`
+	Actions    []string `json:"actions"`     // 为空时生成全部按钮
+	RoleIDs    []string `json:"role_ids"`    // 授权给这些角色
+	PackageIDs []string `json:"package_ids"` // 加入这些租户套餐
+}
+
+// menuScaffoldResult 生成结果
+type menuScaffoldResult struct {
+	MenuID   string    `json:"menu_id"`
+	Created  []menuRef `json:"created"`  // 新建的菜单与按钮
+	Existing []menuRef `json:"existing"` // 已存在而跳过的菜单与按钮
+	Roles    int       `json:"roles"`
+	Packages int       `json:"packages"`
+}
+
+// registerMenuScaffold 注册 POST /api/system/menu/scaffold：为业务集合一键生成页面菜单（C）
+// 及 {collection}:query/add/edit/remove/export/import 按钮（F），可同时授权给角色与租户套餐（仅超级管理员）。
+// 授权的角色属于绑定了套餐的租户时，菜单须在该套餐内（或同时加入该套餐），否则整体回滚。
+// 已存在的页面菜单（perms 为 {collection}:list）与按钮会被复用，可重复调用补全缺失的按钮。
+func registerMenuScaffold(app *pocketbase.PocketBase) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST("/api/system/menu/scaffold", func(e *core.RequestEvent) error {
+			if !isMenuSuperuser(app, e) {
+				return e.ForbiddenError("仅超级管理员可生成菜单", nil)
+			}
+
+			var req menuScaffoldRequest
+			if err := e.BindBody(&req); err != nil {
+				return e.BadRequestError("无效的请求体", err)
+			}
+
+			var result *menuScaffoldResult
+			err := e.App.RunInTransaction(func(txApp core.App) error {
+				var err error
+				result, err = scaffoldCollectionMenu(txApp, req)
+				return err
+			})
+			if err != nil {
+				var apiErr *router.ApiError
+				if errors.As(err, &apiErr) {
+					return apiErr
+				}
+				return e.InternalServerError("生成菜单失败", err)
+			}
+			return tools.JSONSuccess(e, result)
+		}).Bind(apis.RequireAuth())
+
+		return se.Next()
+	})
+}
+
+// scaffoldCollectionMenu 生成集合的页面菜单与按钮权限，并授权给指定角色与租户套餐
+func scaffoldCollectionMenu(app core.App, req menuScaffoldRequest) (*menuScaffoldResult, error) {
+	coll, err := app.FindCollectionByNameOrId(strings.TrimSpace(req.Collection))
+	if err != nil {
+		return nil, apis.NewBadRequestError("集合不存在", err)
+	}
+	name := coll.Name
+
+	parentID := strings.TrimSpace(req.ParentID)
+	if parentID == "" {
+		parentID = "0"
+	}
+	if parentID != "0" {
+		parent, err := app.FindRecordById("menu", parentID)
+		if err != nil {
+			return nil, apis.NewBadRequestError("上级菜单不存在", err)
+		}
+		if parent.GetString("menu_type") != "M" {
+			return nil, apis.NewBadRequestError("上级菜单必须是目录", nil)
+		}
+	}
+
+	actions, err := scaffoldActionSet(req.Actions)
+	if err != nil {
+		return nil, err
+	}
+
+	menuColl, err := app.FindCachedCollectionByNameOrId("menu")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &menuScaffoldResult{Created: []menuRef{}, Existing: []menuRef{}}
+
+	// 页面菜单：按 perms 复用已有菜单
+	page, err := app.FindFirstRecordByFilter("menu", "menu_type='C' && perms={:perms}", dbx.Params{"perms": name + ":list"})
+	if err != nil {
+		page = core.NewRecord(menuColl)
+		page.Set("menu_name", firstNonEmpty(req.MenuName, name))
+		page.Set("parent_id", parentID)
+		page.Set("order_num", req.OrderNum)
+		page.Set("path", firstNonEmpty(req.Path, name))
+		page.Set("component", firstNonEmpty(req.Component, name+"/index"))
+		page.Set("perms", name+":list")
+		page.Set("icon", firstNonEmpty(req.Icon, "#"))
+		page.Set("menu_type", "C")
+		page.Set("is_frame", "1")
+		page.Set("is_cache", "0")
+		page.Set("visible", "0")
+		page.Set("status", "0")
+		if err := app.Save(page); err != nil {
+			return nil, err
+		}
+		result.Created = append(result.Created, menuRef{ID: page.Id, Name: page.GetString("menu_name")})
+	} else {
+		result.Existing = append(result.Existing, menuRef{ID: page.Id, Name: page.GetString("menu_name")})
+	}
+	result.MenuID = page.Id
+
+	// 按钮权限：按 perms 复用页面下已有按钮
+	menuIDs := []string{page.Id}
+	label := page.GetString("menu_name")
+	for i, a := range scaffoldActions {
+		if _, ok := actions[a.Action]; !ok {
+			continue
+		}
+		perm := name + ":" + a.Action
+
+		btn, err := app.FindFirstRecordByFilter("menu", "menu_type='F' && parent_id={:pid} && perms={:perms}",
+			dbx.Params{"pid": page.Id, "perms": perm})
+		if err == nil {
+			result.Existing = append(result.Existing, menuRef{ID: btn.Id, Name: btn.GetString("menu_name")})
+			menuIDs = append(menuIDs, btn.Id)
+			continue
+		}
+
+		btn = core.NewRecord(menuColl)
+		btn.Set("menu_name", label+a.Label)
+		btn.Set("parent_id", page.Id)
+		btn.Set("order_num", i+1)
+		btn.Set("perms", perm)
+		btn.Set("icon", "#")
+		btn.Set("menu_type", "F")
+		btn.Set("is_frame", "1")
+		btn.Set("is_cache", "0")
+		btn.Set("visible", "0")
+		btn.Set("status", "0")
+		if err := app.Save(btn); err != nil {
+			return nil, err
+		}
+		result.Created = append(result.Created, menuRef{ID: btn.Id, Name: btn.GetString("menu_name")})
+		menuIDs = append(menuIDs, btn.Id)
+	}
+
+	// 授权时一并加入上级目录，否则路由树中找不到页面的父节点
+	ancestors, err := menuAncestorIDs(app, page.GetString("parent_id"))
+	if err != nil {
+		return nil, err
+	}
+	grantIDs := append(ancestors, menuIDs...)
+
+	// 先加入套餐，再按角色所属租户的套餐校验授权（与角色分配菜单时的套餐限制一致）
+	for _, pkgID := range req.PackageIDs {
+		pkg, err := app.FindRecordById("tenant_package", pkgID)
+		if err != nil {
+			return nil, apis.NewBadRequestError("租户套餐不存在："+pkgID, err)
+		}
+		pkg.Set("menu_ids", mergeIDs(pkg.GetStringSlice("menu_ids"), grantIDs))
+		if err := app.Save(pkg); err != nil {
+			return nil, err
+		}
+		result.Packages++
+	}
+
+	for _, roleID := range req.RoleIDs {
+		role, err := app.FindRecordById("role", roleID)
+		if err != nil {
+			return nil, apis.NewBadRequestError("角色不存在："+roleID, err)
+		}
+		if err := checkScaffoldRolePackage(app, role, grantIDs); err != nil {
+			return nil, err
+		}
+		if err := grantRoleMenus(app, roleID, grantIDs); err != nil {
+			return nil, err
+		}
+		result.Roles++
+	}
+
+	return result, nil
+}
+
+// scaffoldActionSet 校验并返回需要生成的按钮动作，为空时生成全部
+func scaffoldActionSet(in []string) (map[string]struct{}, error) {
+	known := map[string]struct{}{}
+	for _, a := range scaffoldActions {
+		known[a.Action] = struct{}{}
+	}
+	if len(in) == 0 {
+		return known, nil
+	}
+
+	out := map[string]struct{}{}
+	for _, a := range in {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if _, ok := known[a]; !ok {
+			return nil, apis.NewBadRequestError("不支持的按钮权限："+a, nil)
+		}
+		out[a] = struct{}{}
+	}
+	return out, nil
+}
+
+// menuAncestorIDs 返回 parentID 指向的菜单及其全部上级菜单ID（parentID 为 0 或空时返回空）
+func menuAncestorIDs(app core.App, parentID string) ([]string, error) {
+	ids := []string{}
+	seen := map[string]struct{}{}
+	for parentID != "" && parentID != "0" {
+		if _, ok := seen[parentID]; ok {
+			break
+		}
+		seen[parentID] = struct{}{}
+
+		m, err := app.FindRecordById("menu", parentID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, m.Id)
+		parentID = m.GetString("parent_id")
+	}
+	return ids, nil
+}
+
+// checkScaffoldRolePackage 校验授权菜单均在角色所属租户的套餐内（默认租户或未绑定套餐时不限制）
+func checkScaffoldRolePackage(app core.App, role *core.Record, menuIDs []string) error {
+	allowed, restricted := tools.TenantPackageMenus(app, role.GetString("tenant_id"))
+	if !restricted {
+		return nil
+	}
+
+	outside := 0
+	for _, id := range menuIDs {
+		if _, ok := allowed[id]; !ok {
+			outside++
+		}
+	}
+	if outside > 0 {
+		return apis.NewBadRequestError(fmt.Sprintf("角色 %s 所属租户的套餐缺少 %d 个菜单，请同时选择该租户的套餐", role.GetString("role_name"), outside), nil)
+	}
+	return nil
+}
+
+// grantRoleMenus 为角色补充菜单关联，已存在的关联跳过
+func grantRoleMenus(app core.App, roleID string, menuIDs []string) error {
+	existing := []string{}
+	err := app.DB().Select("menu").From("role_menu").
+		Where(dbx.HashExp{"role": roleID}).
+		Column(&existing)
+	if err != nil {
+		return err
+	}
+
+	coll, err := app.FindCachedCollectionByNameOrId("role_menu")
+	if err != nil {
+		return err
+	}
+	for _, id := range mergeIDs(nil, menuIDs) {
+		if slices.Contains(existing, id) {
+			continue
+		}
+		rm := core.NewRecord(coll)
+		rm.Set("role", roleID)
+		rm.Set("menu", id)
+		if err := app.Save(rm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeIDs 合并并去重
+func mergeIDs(base, add []string) []string {
+	out := make([]string, 0, len(base)+len(add))
+	for _, id := range append(append([]string{}, base...), add...) {
+		if !slices.Contains(out, id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,532 @@
+package menu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// MenuSeed 菜单种子节点（导出/导入的 JSON 树）。
+// Key 为稳定标识：按钮取 perm:权限标识，其他菜单取 path:完整路由地址，用于跨环境匹配同一菜单；
+// 多个菜单的 Key 相同时均追加 #菜单名称（见 assignMenuSeedKeys）。
+type MenuSeed struct {
+	Key          string            `json:"key"`
+	ID           string            `json:"id"`
+	MenuName     string            `json:"menu_name"`
+	MenuNameI18n map[string]string `json:"menu_name_i18n,omitempty"`
+	OrderNum     int64             `json:"order_num"`
+	Path         string            `json:"path"`
+	Component    string            `json:"component"`
+	QueryParam   string            `json:"query_param"`
+	ActiveMenu   string            `json:"active_menu"`
+	IsFrame      string            `json:"is_frame"`
+	IsCache      string            `json:"is_cache"`
+	Affix        string            `json:"affix"`
+	Breadcrumb   string            `json:"breadcrumb"`
+	MenuType     string            `json:"menu_type"`
+	Visible      string            `json:"visible"`
+	Status       string            `json:"status"`
+	Perms        string            `json:"perms"`
+	Icon         string            `json:"icon"`
+	Remark       string            `json:"remark"`
+	Children     []*MenuSeed       `json:"children,omitempty"`
+}
+
+// fields 返回需要同步的字段（不含 parent_id）
+func (s *MenuSeed) fields() map[string]any {
+	return map[string]any{
+		"menu_name":      s.MenuName,
+		"menu_name_i18n": s.MenuNameI18n,
+		"order_num":      s.OrderNum,
+		"path":           s.Path,
+		"component":      s.Component,
+		"query_param":    s.QueryParam,
+		"active_menu":    s.ActiveMenu,
+		"is_frame":       s.IsFrame,
+		"is_cache":       s.IsCache,
+		"affix":          s.Affix,
+		"breadcrumb":     s.Breadcrumb,
+		"menu_type":      s.MenuType,
+		"visible":        s.Visible,
+		"status":         s.Status,
+		"perms":          s.Perms,
+		"icon":           s.Icon,
+		"remark":         s.Remark,
+	}
+}
+
+// menuSeedFieldChange 字段差异
+type menuSeedFieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// menuSeedChange 单个菜单的导入差异
+type menuSeedChange struct {
+	Action   string                         `json:"action"` // add | change | remove
+	Key      string                         `json:"key"`
+	ID       string                         `json:"id"`
+	MenuName string                         `json:"menu_name"`
+	Fields   map[string]menuSeedFieldChange `json:"fields,omitempty"`
+}
+
+// menuSeedReport 导入结果（dry-run 时为预览）
+type menuSeedReport struct {
+	DryRun  bool             `json:"dry_run"`
+	Prune   bool             `json:"prune"`
+	Added   int              `json:"added"`
+	Changed int              `json:"changed"`
+	Removed int              `json:"removed"`
+	Changes []menuSeedChange `json:"changes"`
+}
+
+// registerMenuSeed 注册菜单种子导出/导入：
+//   - 命令：menu export [file.json]、menu import <file.json> [--dry-run] [--prune]
+//   - 接口：GET /api/system/menu/seed 下载菜单树，
+//     POST /api/system/menu/seed?dryRun=true&prune=true（JSON 或 multipart: file）导入（仅超级管理员）
+//
+// 导入按 Key 匹配已有菜单并更新，新菜单沿用种子中的ID；未出现在种子中的菜单仅在 prune 时删除。
+func registerMenuSeed(app *pocketbase.PocketBase) {
+	menuCmd := &cobra.Command{
+		Use:   "menu",
+		Short: "菜单种子导出/导入",
+	}
+
+	menuCmd.AddCommand(&cobra.Command{
+		Use:   "export [file.json]",
+		Short: "导出菜单树（未指定文件时输出到标准输出）",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			seeds, err := exportMenuSeeds(app)
+			if err != nil {
+				return err
+			}
+			data, err := json.MarshalIndent(seeds, "", "  ")
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				fmt.Println(string(data))
+				return nil
+			}
+			return os.WriteFile(args[0], data, 0o644)
+		},
+	})
+
+	importCmd := &cobra.Command{
+		Use:   "import <file.json>",
+		Short: "按 Key 幂等导入菜单树",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			seeds := []*MenuSeed{}
+			if err := json.Unmarshal(data, &seeds); err != nil {
+				return err
+			}
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			prune, _ := cmd.Flags().GetBool("prune")
+
+			report, err := importMenuSeeds(app, seeds, dryRun, prune, "")
+			if err != nil {
+				return err
+			}
+			for _, c := range report.Changes {
+				fmt.Printf("%-6s %s (%s)\n", c.Action, c.Key, c.MenuName)
+				for field, diff := range c.Fields {
+					fmt.Printf("         %s: %v -> %v\n", field, diff.From, diff.To)
+				}
+			}
+			fmt.Printf("新增 %d，修改 %d，删除 %d", report.Added, report.Changed, report.Removed)
+			if dryRun {
+				fmt.Print("（dry-run，未写入）")
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+	importCmd.Flags().Bool("dry-run", false, "只显示差异，不写入")
+	importCmd.Flags().Bool("prune", false, "删除种子中不存在的菜单")
+	menuCmd.AddCommand(importCmd)
+
+	app.RootCmd.AddCommand(menuCmd)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/system/menu/seed", func(e *core.RequestEvent) error {
+			if !isMenuSuperuser(app, e) {
+				return e.ForbiddenError("仅超级管理员可导出菜单", nil)
+			}
+			seeds, err := exportMenuSeeds(e.App)
+			if err != nil {
+				return e.InternalServerError("导出菜单失败", err)
+			}
+			e.Response.Header().Set("Content-Disposition", "attachment; filename=\"menu.json\"")
+			return e.JSON(http.StatusOK, seeds)
+		}).Bind(apis.RequireAuth())
+
+		se.Router.POST("/api/system/menu/seed", func(e *core.RequestEvent) error {
+			if !isMenuSuperuser(app, e) {
+				return e.ForbiddenError("仅超级管理员可导入菜单", nil)
+			}
+
+			var reader io.Reader = e.Request.Body
+			if file, _, err := e.Request.FormFile("file"); err == nil {
+				defer file.Close()
+				reader = file
+			}
+			seeds := []*MenuSeed{}
+			if err := json.NewDecoder(reader).Decode(&seeds); err != nil {
+				return e.BadRequestError("菜单种子格式错误", err)
+			}
+
+			q := e.Request.URL.Query()
+			report, err := importMenuSeeds(e.App, seeds, q.Get("dryRun") == "true", q.Get("prune") == "true", e.Auth.Id)
+			if err != nil {
+				return e.BadRequestError("导入菜单失败："+err.Error(), nil)
+			}
+			return tools.JSONSuccess(e, report)
+		}).Bind(apis.RequireAuth())
+
+		return se.Next()
+	})
+}
+
+// loadAllMenus 查询全部菜单（含按钮与停用菜单）
+func loadAllMenus(app core.App) ([]Menu, error) {
+	menus := []Menu{}
+	err := app.DB().Select("*").From("menu").All(&menus)
+	return menus, err
+}
+
+// exportMenuSeeds 导出完整菜单树
+func exportMenuSeeds(app core.App) ([]*MenuSeed, error) {
+	menus, err := loadAllMenus(app)
+	if err != nil {
+		return nil, err
+	}
+	seeds := toMenuSeeds(buildMenuTree(menus))
+	if err := assignMenuSeedKeys(seeds); err != nil {
+		return nil, err
+	}
+	return seeds, nil
+}
+
+// toMenuSeeds 将菜单树转换为种子树（Key 由 assignMenuSeedKeys 计算）
+func toMenuSeeds(nodes []*MenuNode) []*MenuSeed {
+	out := make([]*MenuSeed, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, &MenuSeed{
+			ID:           n.ID,
+			MenuName:     n.MenuName,
+			MenuNameI18n: n.i18nNames(),
+			OrderNum:     n.OrderNum,
+			Path:         n.Path,
+			Component:    n.Component,
+			QueryParam:   n.QueryParam,
+			ActiveMenu:   n.ActiveMenu,
+			IsFrame:      n.IsFrame,
+			IsCache:      n.IsCache,
+			Affix:        n.Affix,
+			Breadcrumb:   n.Breadcrumb,
+			MenuType:     n.MenuType,
+			Visible:      n.Visible,
+			Status:       n.Status,
+			Perms:        n.Perms,
+			Icon:         n.Icon,
+			Remark:       n.Remark,
+			Children:     toMenuSeeds(n.Children),
+		})
+	}
+	return out
+}
+
+// assignMenuSeedKeys 计算种子树中每个菜单的 Key。基础 Key 重复时（如多个按钮使用同一权限标识），
+// 所有重复项都追加 #菜单名称，结果与菜单顺序无关；追加后仍重复的视为冲突，返回错误。
+func assignMenuSeedKeys(seeds []*MenuSeed) error {
+	type keyed struct {
+		seed *MenuSeed
+		base string
+	}
+	all := []keyed{}
+	var walk func(nodes []*MenuSeed, parentPath string)
+	walk = func(nodes []*MenuSeed, parentPath string) {
+		for _, s := range nodes {
+			base, fullPath := menuSeedKey(Menu{MenuName: s.MenuName, Path: s.Path, MenuType: s.MenuType, Perms: s.Perms}, parentPath)
+			all = append(all, keyed{seed: s, base: base})
+			walk(s.Children, fullPath)
+		}
+	}
+	walk(seeds, "")
+
+	bases := map[string]int{}
+	for _, k := range all {
+		bases[k.base]++
+	}
+	keys := map[string]int{}
+	for _, k := range all {
+		k.seed.Key = k.base
+		if bases[k.base] > 1 {
+			k.seed.Key += "#" + k.seed.MenuName
+		}
+		keys[k.seed.Key]++
+	}
+	for _, k := range all {
+		if n := keys[k.seed.Key]; n > 1 {
+			return fmt.Errorf("菜单 Key 重复：%s（%d 个菜单），请修改权限标识或菜单名称", k.seed.Key, n)
+		}
+	}
+	return nil
+}
+
+// menuSeedKey 计算菜单的基础 Key 与完整路由地址
+func menuSeedKey(m Menu, parentPath string) (key, fullPath string) {
+	p := strings.Trim(strings.TrimSpace(m.Path), "/")
+	switch {
+	case isHTTP(m.Path):
+		fullPath = strings.TrimSpace(m.Path)
+	case p == "":
+		fullPath = parentPath
+	default:
+		fullPath = parentPath + "/" + p
+	}
+
+	perms := strings.TrimSpace(m.Perms)
+	switch {
+	case m.MenuType == "F" && perms != "":
+		key = "perm:" + perms
+	case m.MenuType == "F":
+		key = "path:" + parentPath + "#" + m.MenuName
+	default:
+		key = "path:" + fullPath
+	}
+	return key, fullPath
+}
+
+// importMenuSeeds 按 Key 将种子树合并到菜单表；dryRun 时只计算差异，
+// prune 时种子中不存在的菜单以 operator 的名义移入回收站
+func importMenuSeeds(app core.App, seeds []*MenuSeed, dryRun, prune bool, operator string) (*menuSeedReport, error) {
+	report := &menuSeedReport{DryRun: dryRun, Prune: prune, Changes: []menuSeedChange{}}
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		menus, err := loadAllMenus(txApp)
+		if err != nil {
+			return err
+		}
+
+		// 已有菜单按 Key 索引（与导出相同的计算方式）
+		existing := map[string]*MenuSeed{}
+		existingKeys := []string{}
+		usedIDs := map[string]struct{}{}
+		var index func(nodes []*MenuSeed)
+		index = func(nodes []*MenuSeed) {
+			for _, n := range nodes {
+				existing[n.Key] = n
+				existingKeys = append(existingKeys, n.Key)
+				usedIDs[n.ID] = struct{}{}
+				index(n.Children)
+			}
+		}
+		currentSeeds := toMenuSeeds(buildMenuTree(menus))
+		if err := assignMenuSeedKeys(currentSeeds); err != nil {
+			return fmt.Errorf("当前菜单表中%w", err)
+		}
+		index(currentSeeds)
+
+		// 以当前环境重新计算种子的 Key，避免种子中的 Key 与路径不一致
+		if err := assignMenuSeedKeys(seeds); err != nil {
+			return fmt.Errorf("种子中%w", err)
+		}
+
+		coll, err := txApp.FindCachedCollectionByNameOrId("menu")
+		if err != nil {
+			return err
+		}
+
+		keys := map[string]struct{}{}
+		var apply func(nodes []*MenuSeed, parentID string) error
+		apply = func(nodes []*MenuSeed, parentID string) error {
+			for _, s := range nodes {
+				if strings.TrimSpace(s.MenuName) == "" {
+					return fmt.Errorf("菜单名称不能为空（%s）", s.Key)
+				}
+				if s.MenuType != "M" && s.MenuType != "C" && s.MenuType != "F" {
+					return fmt.Errorf("菜单类型错误：%s（%s）", s.MenuType, s.MenuName)
+				}
+
+				key := s.Key
+				keys[key] = struct{}{}
+
+				fields := s.fields()
+				fields["parent_id"] = parentID
+
+				var id string
+				if cur, ok := existing[key]; ok {
+					id = cur.ID
+					diff := diffMenuSeed(cur, fields, menuParentID(menus, cur.ID))
+					if len(diff) > 0 {
+						report.Changed++
+						report.Changes = append(report.Changes, menuSeedChange{Action: "change", Key: key, ID: id, MenuName: s.MenuName, Fields: diff})
+						if !dryRun {
+							rec, err := txApp.FindRecordById("menu", id)
+							if err != nil {
+								return err
+							}
+							for k, v := range fields {
+								rec.Set(k, v)
+							}
+							if err := txApp.Save(rec); err != nil {
+								return err
+							}
+						}
+					}
+				} else {
+					// 新菜单沿用种子ID（ID 已被其他菜单占用时重新生成）
+					id = s.ID
+					if _, used := usedIDs[id]; used || id == "" {
+						id = core.GenerateDefaultRandomId()
+					}
+					usedIDs[id] = struct{}{}
+
+					report.Added++
+					report.Changes = append(report.Changes, menuSeedChange{Action: "add", Key: key, ID: id, MenuName: s.MenuName})
+					if !dryRun {
+						rec := core.NewRecord(coll)
+						rec.Id = id
+						for k, v := range fields {
+							rec.Set(k, v)
+						}
+						if err := saveSeedMenu(txApp, rec); err != nil {
+							return fmt.Errorf("保存菜单 %s 失败：%w", s.MenuName, err)
+						}
+					}
+				}
+
+				if err := apply(s.Children, id); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := apply(seeds, "0"); err != nil {
+			return err
+		}
+
+		removed := map[string]struct{}{}
+		for _, key := range existingKeys {
+			if _, ok := keys[key]; ok {
+				continue
+			}
+			cur := existing[key]
+			// 未开启 prune 时删除项仅作提示
+			report.Removed++
+			report.Changes = append(report.Changes, menuSeedChange{Action: "remove", Key: key, ID: cur.ID, MenuName: cur.MenuName})
+			removed[cur.ID] = struct{}{}
+		}
+		if dryRun || !prune || len(removed) == 0 {
+			return nil
+		}
+
+		// 按导入后的上下级关系，只回收最上层的待删除菜单，其下级随子树一并移入回收站
+		current, err := loadAllMenus(txApp)
+		if err != nil {
+			return err
+		}
+		for _, key := range existingKeys {
+			cur := existing[key]
+			if _, ok := removed[cur.ID]; !ok || hasRemovedAncestor(current, cur.ID, removed) {
+				continue
+			}
+			rec, err := txApp.FindRecordById("menu", cur.ID)
+			if err != nil {
+				return fmt.Errorf("查找待删除菜单 %s 失败：%w", cur.MenuName, err)
+			}
+			if err := recycleMenuTree(txApp, rec, operator); err != nil {
+				return err
+			}
+			if err := txApp.Delete(rec); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// saveSeedMenu 保存新增的种子菜单（回收站恢复菜单时同样使用）。种子ID可能是 RuoYi 的数字ID，
+// 不满足 id 字段的长度校验：此时 ID 仍需满足其余规则，记录用临时ID完成校验后再按原ID保存
+func saveSeedMenu(app core.App, rec *core.Record) error {
+	idField, ok := rec.Collection().Fields.GetByName(core.FieldNameId).(*core.TextField)
+	if !ok || idField.ValidatePlainValue(rec.Id) == nil {
+		return app.Save(rec)
+	}
+
+	anyLength := *idField
+	anyLength.Min, anyLength.Max = 0, 0
+	if err := anyLength.ValidatePlainValue(rec.Id); err != nil {
+		return fmt.Errorf("菜单ID %s 格式错误：%w", rec.Id, err)
+	}
+
+	id := rec.Id
+	rec.Id = core.GenerateDefaultRandomId()
+	err := app.Validate(rec)
+	rec.Id = id
+	if err != nil {
+		return err
+	}
+	return app.SaveNoValidate(rec)
+}
+
+// hasRemovedAncestor 判断菜单的某个上级是否也在待删除集合中
+func hasRemovedAncestor(menus []Menu, id string, removed map[string]struct{}) bool {
+	seen := map[string]struct{}{id: {}}
+	for parent := menuParentID(menus, id); parent != "" && parent != "0"; parent = menuParentID(menus, parent) {
+		if _, ok := removed[parent]; ok {
+			return true
+		}
+		if _, ok := seen[parent]; ok {
+			return false
+		}
+		seen[parent] = struct{}{}
+	}
+	return false
+}
+
+// menuParentID 查找菜单当前的上级ID
+func menuParentID(menus []Menu, id string) string {
+	for _, m := range menus {
+		if m.ID == id {
+			return m.ParentID
+		}
+	}
+	return ""
+}
+
+// diffMenuSeed 比较已有菜单与种子字段，返回有差异的字段
+func diffMenuSeed(cur *MenuSeed, fields map[string]any, curParentID string) map[string]menuSeedFieldChange {
+	old := cur.fields()
+	old["parent_id"] = curParentID
+
+	diff := map[string]menuSeedFieldChange{}
+	for k, v := range fields {
+		if fmt.Sprint(old[k]) != fmt.Sprint(v) {
+			diff[k] = menuSeedFieldChange{From: old[k], To: v}
+		}
+	}
+	return diff
+}
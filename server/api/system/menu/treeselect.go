@@ -0,0 +1,267 @@
+package menu
+
+import (
+	"slices"
+	"strings"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// MenuTreeSelect 菜单下拉树节点（角色、租户套餐分配菜单使用）
+type MenuTreeSelect struct {
+	ID       string           `json:"id"`
+	ParentID string           `json:"parentId"`
+	Label    string           `json:"label"`
+	Weight   int64            `json:"weight"`
+	MenuType string           `json:"menuType"`
+	Icon     string           `json:"icon"`
+	Key      string           `json:"key"`
+	Children []MenuTreeSelect `json:"children"`
+}
+
+// registerMenuTreeSelect 注册菜单下拉树接口：
+//   - GET /api/system/menu/treeselect 当前用户可分配的菜单树（含按钮）；
+//   - GET /api/system/menu/roleMenuTreeselect/{roleId} 额外返回角色已分配的菜单 checkedKeys（system:role:query）；
+//   - GET /api/system/menu/tenantPackageMenuTreeselect/{packageId} 租户套餐使用，不含租户管理菜单
+//     （仅超级管理员，且需 system:tenantPackage:query）。
+//
+// 超级管理员可分配全部菜单，其他用户只能分配自己拥有且在当前租户套餐内的菜单。
+func registerMenuTreeSelect(app *pocketbase.PocketBase) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/system/menu/treeselect", func(e *core.RequestEvent) error {
+			menus, err := grantableMenus(app, e)
+			if err != nil {
+				return e.InternalServerError("查询菜单失败", err)
+			}
+			return tools.JSONSuccess(e, buildMenuTreeSelect(buildMenuTree(menus)))
+		}).Bind(apis.RequireAuth())
+
+		se.Router.GET("/api/system/menu/roleMenuTreeselect/{roleId}", func(e *core.RequestEvent) error {
+			role, err := e.App.FindRecordById("role", e.Request.PathValue("roleId"))
+			if err != nil {
+				return e.NotFoundError("角色不存在", err)
+			}
+			if !isMenuSuperuser(app, e) && role.GetString("tenant_id") != tools.GetUserTenant(e) {
+				return e.ForbiddenError("没有权限访问该角色", nil)
+			}
+
+			menus, err := grantableMenus(app, e)
+			if err != nil {
+				return e.InternalServerError("查询菜单失败", err)
+			}
+
+			roleMenus := []Menu{}
+			err = e.App.DB().Select("m.id", "m.parent_id").From("menu as m").
+				InnerJoin("role_menu as rm", dbx.NewExp("rm.menu = m.id")).
+				Where(dbx.HashExp{"rm.role": role.Id}).
+				All(&roleMenus)
+			if err != nil {
+				return e.InternalServerError("查询角色菜单失败", err)
+			}
+
+			return tools.JSONSuccess(e, map[string]any{
+				"checkedKeys": checkedMenuKeys(roleMenus, role.GetBool("menu_check_strictly")),
+				"menus":       buildMenuTreeSelect(buildMenuTree(menus)),
+			})
+		}).Bind(apis.RequireAuth()).BindFunc(requireMenuPermission(app, "system:role:query"))
+
+		se.Router.GET("/api/system/menu/tenantPackageMenuTreeselect/{packageId}", func(e *core.RequestEvent) error {
+			if !isMenuSuperuser(app, e) {
+				return e.ForbiddenError("仅超级管理员可维护租户套餐", nil)
+			}
+
+			menus := []Menu{}
+			err := e.App.DB().Select("*").From("menu").
+				Where(dbx.HashExp{"status": "0"}).
+				All(&menus)
+			if err != nil {
+				return e.InternalServerError("查询菜单失败", err)
+			}
+			menus = excludeTenantManagementMenus(menus)
+
+			checked := []string{}
+			if pkgID := e.Request.PathValue("packageId"); pkgID != "" && pkgID != "0" {
+				pkg, err := e.App.FindRecordById("tenant_package", pkgID)
+				if err != nil {
+					return e.NotFoundError("租户套餐不存在", err)
+				}
+
+				pkgMenus := []Menu{}
+				if ids := pkg.GetStringSlice("menu_ids"); len(ids) > 0 {
+					err = e.App.DB().Select("id", "parent_id").From("menu").
+						Where(dbx.In("id", toAnySlice(ids)...)).
+						All(&pkgMenus)
+					if err != nil {
+						return e.InternalServerError("查询套餐菜单失败", err)
+					}
+				}
+				checked = checkedMenuKeys(pkgMenus, pkg.GetBool("menu_check_strictly"))
+			}
+
+			return tools.JSONSuccess(e, map[string]any{
+				"checkedKeys": checked,
+				"menus":       buildMenuTreeSelect(buildMenuTree(menus)),
+			})
+		}).Bind(apis.RequireAuth()).BindFunc(requireMenuPermission(app, "system:tenantPackage:query"))
+
+		return se.Next()
+	})
+}
+
+// tenantManagementPermPrefixes 租户管理菜单的权限标识前缀，这些菜单只属于平台，不能加入租户套餐
+var tenantManagementPermPrefixes = []string{"system:tenant:", "system:tenantPackage:"}
+
+// excludeTenantManagementMenus 按权限标识排除租户管理菜单；
+// 下级菜单因此全部被排除的目录（如“租户管理”目录本身）一并排除
+func excludeTenantManagementMenus(menus []Menu) []Menu {
+	excluded := map[string]struct{}{}
+	for _, m := range menus {
+		for _, prefix := range tenantManagementPermPrefixes {
+			if strings.HasPrefix(strings.TrimSpace(m.Perms), prefix) {
+				excluded[m.ID] = struct{}{}
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		children := map[string]int{}
+		kept := map[string]int{}
+		for _, m := range menus {
+			children[m.ParentID]++
+			if _, ok := excluded[m.ID]; !ok {
+				kept[m.ParentID]++
+			}
+		}
+		for _, m := range menus {
+			if _, ok := excluded[m.ID]; ok {
+				continue
+			}
+			if children[m.ID] > 0 && kept[m.ID] == 0 {
+				excluded[m.ID] = struct{}{}
+				changed = true
+			}
+		}
+	}
+
+	out := make([]Menu, 0, len(menus))
+	for _, m := range menus {
+		if _, ok := excluded[m.ID]; !ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// requireMenuPermission 与 auth.RBAC 相同的权限校验（菜单包不能引用 auth 包）：
+// 超级管理员放行，其他用户需拥有 permission 或通配权限 *:*:*
+func requireMenuPermission(app *pocketbase.PocketBase, permission string) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		if e.Auth == nil {
+			return e.UnauthorizedError("未登录或无权限", nil)
+		}
+		if isMenuSuperuser(app, e) {
+			return e.Next()
+		}
+		perms := GetAllPermissionsByUser(e, e.Auth.Id)
+		if !slices.Contains(perms, "*:*:*") && !slices.Contains(perms, permission) {
+			return e.ForbiddenError("权限不足", nil)
+		}
+		return e.Next()
+	}
+}
+
+// isMenuSuperuser 平台超级管理员账号或拥有超级管理员角色
+func isMenuSuperuser(app *pocketbase.PocketBase, e *core.RequestEvent) bool {
+	return e.Auth != nil && (e.Auth.IsSuperuser() || tools.IsRoleSuperuser(app, e.Auth.Id))
+}
+
+// grantableMenus 当前用户可分配的正常菜单（含按钮）：
+// 超级管理员为全部菜单，其他用户为自己角色拥有的菜单，并限制在当前租户套餐内
+func grantableMenus(app *pocketbase.PocketBase, e *core.RequestEvent) ([]Menu, error) {
+	menus := []Menu{}
+
+	if isMenuSuperuser(app, e) {
+		err := e.App.DB().Select("*").From("menu").
+			Where(dbx.HashExp{"status": "0"}).
+			All(&menus)
+		return menus, err
+	}
+
+	err := e.App.DB().Select("m.*").From("menu as m").
+		InnerJoin("role_menu as rm", dbx.NewExp("rm.menu = m.id")).
+		InnerJoin("user_role as ur", dbx.NewExp("ur.role = rm.role")).
+		Where(dbx.HashExp{"ur.user": e.Auth.Id}).
+		AndWhere(dbx.HashExp{"m.status": "0"}).
+		All(&menus)
+	if err != nil {
+		return nil, err
+	}
+	menus = uniqueMenus(menus)
+
+	allowed, restricted := tools.TenantPackageMenus(e.App, tools.GetUserTenant(e))
+	if !restricted {
+		return menus, nil
+	}
+	out := menus[:0]
+	for _, m := range menus {
+		if _, ok := allowed[m.ID]; ok {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// checkedMenuKeys 返回已选菜单ID；父子联动（menu_check_strictly）时去掉有子节点被选中的父菜单，
+// 避免前端树勾选父节点时自动全选其子节点
+func checkedMenuKeys(menus []Menu, checkStrictly bool) []string {
+	parents := map[string]struct{}{}
+	if checkStrictly {
+		for _, m := range menus {
+			parents[strings.TrimSpace(m.ParentID)] = struct{}{}
+		}
+	}
+
+	keys := make([]string, 0, len(menus))
+	for _, m := range menus {
+		if _, ok := parents[m.ID]; ok {
+			continue
+		}
+		keys = append(keys, m.ID)
+	}
+	return keys
+}
+
+func buildMenuTreeSelect(nodes []*MenuNode) []MenuTreeSelect {
+	out := make([]MenuTreeSelect, 0, len(nodes))
+	for _, n := range nodes {
+		icon := n.Icon
+		if icon == "" {
+			icon = "#"
+		}
+		out = append(out, MenuTreeSelect{
+			ID:       n.ID,
+			ParentID: n.ParentID,
+			Label:    n.MenuName,
+			Weight:   n.OrderNum,
+			MenuType: n.MenuType,
+			Icon:     icon,
+			Key:      n.ID,
+			Children: buildMenuTreeSelect(n.Children),
+		})
+	}
+	return out
+}
+
+func toAnySlice(ids []string) []any {
+	out := make([]any, len(ids))
+	for i, id := range ids {
+		out[i] = id
+	}
+	return out
+}
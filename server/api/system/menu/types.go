@@ -1,21 +1,28 @@
 package menu
 
+import "github.com/pocketbase/pocketbase/tools/types"
+
 // Menu 模型映射（尽量贴合 SQL 定义）。
 type Menu struct {
-	ID         string `db:"id" json:"id"`
-	MenuName   string `db:"menu_name" json:"menu_name"`
-	ParentID   string `db:"parent_id" json:"parent_id"`
-	OrderNum   int64  `db:"order_num" json:"order_num"`
-	Path       string `db:"path" json:"path"`
-	Component  string `db:"component" json:"component"`
-	QueryParam string `db:"query_param" json:"query_param"`
-	IsFrame    string `db:"is_frame" json:"is_frame"`
-	IsCache    string `db:"is_cache" json:"is_cache"`
-	MenuType   string `db:"menu_type" json:"menu_type"`
-	Visible    string `db:"visible" json:"visible"`
-	Status     string `db:"status" json:"status"`
-	Perms      string `db:"perms" json:"perms"`
-	Icon       string `db:"icon" json:"icon"`
+	ID           string        `db:"id" json:"id"`
+	MenuName     string        `db:"menu_name" json:"menu_name"`
+	MenuNameI18n types.JSONRaw `db:"menu_name_i18n" json:"menu_name_i18n"` // 各语言的菜单名称，如 {"en-US": "System"}
+	ParentID     string        `db:"parent_id" json:"parent_id"`
+	OrderNum     int64         `db:"order_num" json:"order_num"`
+	Path         string        `db:"path" json:"path"`
+	Component    string        `db:"component" json:"component"`
+	QueryParam   string        `db:"query_param" json:"query_param"`
+	ActiveMenu   string        `db:"active_menu" json:"active_menu"`
+	IsFrame      string        `db:"is_frame" json:"is_frame"`
+	IsCache      string        `db:"is_cache" json:"is_cache"`
+	Affix        string        `db:"affix" json:"affix"`
+	Breadcrumb   string        `db:"breadcrumb" json:"breadcrumb"`
+	MenuType     string        `db:"menu_type" json:"menu_type"`
+	Visible      string        `db:"visible" json:"visible"`
+	Status       string        `db:"status" json:"status"`
+	Perms        string        `db:"perms" json:"perms"`
+	Icon         string        `db:"icon" json:"icon"`
+	Remark       string        `db:"remark" json:"remark"`
 }
 
 // MenuNode 菜单树节点结构体
@@ -26,10 +33,14 @@ type MenuNode struct {
 
 // RouteMeta 前端路由元信息结构体
 type RouteMeta struct {
-	Title   string  `json:"title"`
-	Icon    string  `json:"icon,omitempty"`
-	NoCache bool    `json:"noCache"`
-	Link    *string `json:"link"`
+	Title      string  `json:"title"`
+	Icon       string  `json:"icon,omitempty"`
+	NoCache    bool    `json:"noCache"`
+	Link       *string `json:"link"`
+	Query      string  `json:"query,omitempty"`      // 路由参数（JSON 字符串）
+	ActiveMenu string  `json:"activeMenu,omitempty"` // 隐藏页面访问时高亮的菜单路径
+	Affix      bool    `json:"affix"`                // 固定在标签栏
+	Breadcrumb bool    `json:"breadcrumb"`           // 是否显示在面包屑
 }
 
 // Router 前端路由结构体
@@ -47,4 +58,5 @@ type Router struct {
 	Name       string    `json:"name"`
 	Path       string    `json:"path"`
 	Redirect   string    `json:"redirect,omitempty"`
+	Query      string    `json:"query,omitempty"`
 }
@@ -30,6 +30,7 @@ func syncOSS(e *core.RecordRequestEvent) error {
 	e.Record.Set("file_name", file.Name)
 	e.Record.Set("original_name", file.OriginalName)
 	e.Record.Set("file_suffix", getSuffix(file.OriginalName))
+	e.Record.Set("file_size", file.Size)
 
 	return e.Next()
 }
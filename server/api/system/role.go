@@ -1,6 +1,7 @@
 package system
 
 import (
+	"fmt"
 	"pocketbase-ruoyi/tools"
 
 	"github.com/pocketbase/dbx"
@@ -75,6 +76,9 @@ func syncRole(e *core.RecordRequestEvent) error {
 	e.BindBody(payload)
 
 	if e.Request.Header.Get("X-Menu") == "true" {
+		if err := checkRoleMenusInPackage(e, payload.MenuIds); err != nil {
+			return err
+		}
 		tools.CacheIdsForCreate(e, "X-Menu", tempRoleMenus, payload.MenuIds)
 	}
 	tools.ReplaceJoinTableForUpdate(
@@ -110,6 +114,30 @@ func syncRole(e *core.RecordRequestEvent) error {
 	return e.Next()
 }
 
+// checkRoleMenusInPackage 角色只能分配所属租户套餐内的菜单
+func checkRoleMenusInPackage(e *core.RecordRequestEvent, menuIDs []string) error {
+	tenantID := e.Record.GetString("tenant_id")
+	if tenantID == "" {
+		tenantID = tools.GetUserTenant(e.RequestEvent)
+	}
+
+	allowed, restricted := tools.TenantPackageMenus(e.App, tenantID)
+	if !restricted {
+		return nil
+	}
+
+	outside := 0
+	for _, id := range menuIDs {
+		if _, ok := allowed[id]; !ok {
+			outside++
+		}
+	}
+	if outside > 0 {
+		return e.BadRequestError(fmt.Sprintf("存在 %d 个菜单不在租户套餐内，无法分配", outside), nil)
+	}
+	return nil
+}
+
 func syncRoleAfter(e *core.RecordEvent) error {
 	tools.ProcessAfterCreateTempIds(e, tempRoleMenus, "role_menu", func(nr, parent *core.Record, menuID string) {
 		nr.Set("role", parent.Id)
@@ -1,17 +1,50 @@
 package system
 
 import (
+	"time"
+
+	"pocketbase-ruoyi/api/monitor"
 	"pocketbase-ruoyi/tools"
 
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
 )
 
+// tenantSwitchTTL 租户切换会话有效期，过期后自动回到本人所属租户
+const tenantSwitchTTL = 2 * time.Hour
+
 // RegisterSystemTenant exposes system/tenant related custom endpoints.
-// Adds: PUT /api/system/tenant/dynamic/{tenantId} to set a temporary tenant context for current user.
+//   - GET /api/system/tenant/dynamic/{tenantId} switches the current login token to another tenant (expires after tenantSwitchTTL).
+//   - GET /api/system/tenant/dynamic/clear switches back to the user's own tenant.
+//   - GET /api/system/tenant/dynamic/sessions lists active switch sessions; DELETE .../sessions/{id} revokes one.
+//   - Superadmins may send the X-Tenant-Id header to act in another tenant for a single request.
+//
+// Every switch, clear and revoke is recorded in oper_log under the target tenant with the real operator.
 func RegisterSystemTenant(app *pocketbase.PocketBase) {
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		// X-Tenant-Id: runs right after the auth token is loaded, before any other custom middleware
+		se.Router.Bind(&hook.Handler[*core.RequestEvent]{
+			Id:       "tenantHeaderOverride",
+			Priority: apis.DefaultBodyLimitMiddlewarePriority + 1,
+			Func: func(e *core.RequestEvent) error {
+				tenantID := e.Request.Header.Get(tools.TenantHeader)
+				if tenantID == "" {
+					return e.Next()
+				}
+				if !isSuperuserByEvent(e) {
+					return e.ForbiddenError("仅超级管理员可以指定租户", nil)
+				}
+				if _, err := e.App.FindRecordById("tenant", tenantID); err != nil {
+					return e.BadRequestError("租户不存在", err)
+				}
+				tools.SetRequestTenant(e, tenantID)
+				return e.Next()
+			},
+		})
+
 		// Set temporary tenant for current authenticated user
 		se.Router.GET("/api/system/tenant/dynamic/{tenantId}", func(e *core.RequestEvent) error {
 			ri, err := e.RequestInfo()
@@ -43,16 +76,68 @@ func RegisterSystemTenant(app *pocketbase.PocketBase) {
 			}
 
 			// 设置临时租户上下文
-			tools.SetUserTenant(e, tenantID)
+			s := tools.SetUserTenant(e, tenantID, tenantSwitchTTL)
+			recordTenantSwitchLog(e, "切换租户", tenantID)
+
 			return tools.JSONSuccess(e, map[string]any{
-				"tenantId": tenantID,
+				"tenantId":  tenantID,
+				"expiresAt": s.ExpiresAt,
 			})
 		})
 
+		// Switch back to the user's own tenant
+		se.Router.GET("/api/system/tenant/dynamic/clear", func(e *core.RequestEvent) error {
+			if e.Auth == nil {
+				return e.UnauthorizedError("未登录或无权限", nil)
+			}
+			if s := tools.ClearUserTenant(e); s != nil {
+				recordTenantSwitchLog(e, "清除租户切换", s.TenantID)
+			}
+			return tools.JSONSuccess(e, nil)
+		})
+
+		// List active switch sessions
+		se.Router.GET("/api/system/tenant/dynamic/sessions", func(e *core.RequestEvent) error {
+			if !isSuperuserByEvent(e) {
+				return e.ForbiddenError("没有权限", nil)
+			}
+			return tools.JSONSuccess(e, tools.ListTenantSwitches(e.App))
+		})
+
+		// Revoke a switch session
+		se.Router.DELETE("/api/system/tenant/dynamic/sessions/{id}", func(e *core.RequestEvent) error {
+			if !isSuperuserByEvent(e) {
+				return e.ForbiddenError("没有权限", nil)
+			}
+			s := tools.RevokeTenantSwitch(e.App, e.Request.PathValue("id"))
+			if s == nil {
+				return e.NotFoundError("切换会话不存在或已过期", nil)
+			}
+			recordTenantSwitchLog(e, "撤销租户切换（"+s.UserName+"）", s.TenantID)
+			return tools.JSONSuccess(e, true)
+		})
+
 		return se.Next()
 	})
 }
 
+// recordTenantSwitchLog 记录租户切换相关操作：tenant_id 为目标租户，oper_name/oper_tenant_id 为实际操作人
+func recordTenantSwitchLog(e *core.RequestEvent, title, tenantID string) {
+	err := monitor.RecordOperLog(e, monitor.OperLogInput{
+		TenantID:      tenantID,
+		Title:         title,
+		BusinessType:  "0",
+		OperatorType:  "1",
+		Status:        "0",
+		Method:        e.Request.URL.Path,
+		RequestMethod: e.Request.Method,
+		OperParam:     `{"tenantId":"` + tenantID + `"}`,
+	})
+	if err != nil {
+		e.App.Logger().Error("记录租户切换日志失败", "tenant", tenantID, "error", err)
+	}
+}
+
 // isSuperuserByEvent 判断当前请求用户是否为超级管理员（包含 e.Auth.IsSuperuser 与 role_key=superadmin）
 func isSuperuserByEvent(e *core.RequestEvent) bool {
 	if e.Auth == nil {
@@ -0,0 +1,640 @@
+package tenant
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"pocketbase-ruoyi/api/auth"
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/spf13/cobra"
+)
+
+// tenantArchiveVersion 归档格式版本
+const tenantArchiveVersion = 1
+
+const (
+	archiveManifestName = "manifest.json"
+	archiveRecordsDir   = "records/"
+	archiveFilesDir     = "files/"
+)
+
+// archiveSoftRefs 非 relation 类型但保存记录ID的字段 -> 被引用的集合，恢复时随ID一起重映射
+var archiveSoftRefs = map[string]string{
+	"create_by":   "users",
+	"update_by":   "users",
+	"create_dept": "dept",
+	"update_dept": "dept",
+	"dept_id":     "dept",
+	"leader":      "users",
+}
+
+// tenantArchiveManifest 归档清单
+type tenantArchiveManifest struct {
+	Version     int                       `json:"version"`
+	TenantID    string                    `json:"tenant_id"`
+	ExportedAt  types.DateTime            `json:"exported_at"`
+	Collections []tenantArchiveCollection `json:"collections"`
+	Files       int                       `json:"files"`
+}
+
+// tenantArchiveCollection 归档中单个集合的记录数
+type tenantArchiveCollection struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// archiveRow 归档中的一行原始数据（列名 -> 值，NULL 为 nil）
+type archiveRow map[string]any
+
+// registerTenantArchive 注册单租户导出/恢复：
+//   - 命令：tenant-export <tenantId> <file.zip>、tenant-restore <file.zip> [--tenant 新租户ID]
+//   - 接口：GET /api/system/tenant/archive/{tenantId} 下载归档，
+//     POST /api/system/tenant/archive/restore（multipart: file, tenant_id）恢复归档
+func registerTenantArchive(app *pocketbase.PocketBase) {
+	app.RootCmd.AddCommand(&cobra.Command{
+		Use:   "tenant-export <tenantId> <file.zip>",
+		Short: "导出单个租户的全部数据与文件",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Create(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			manifest, err := exportTenantArchive(app, args[0], f)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("已导出租户 %s：%d 个集合，%d 个文件\n", manifest.TenantID, len(manifest.Collections), manifest.Files)
+			return nil
+		},
+	})
+
+	restoreCmd := &cobra.Command{
+		Use:   "tenant-restore <file.zip>",
+		Short: "从归档恢复单个租户（重新生成记录ID）",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			targetTenantID, _ := cmd.Flags().GetString("tenant")
+
+			report, err := restoreTenantArchive(app, data, targetTenantID)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("已恢复为租户 %s：%d 个集合，%d 个文件\n", report.TenantID, len(report.Collections), report.Files)
+			return nil
+		},
+	}
+	restoreCmd.Flags().String("tenant", "", "恢复后的租户ID（默认沿用归档中的租户ID）")
+	app.RootCmd.AddCommand(restoreCmd)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/system/tenant/archive/{tenantId}", func(e *core.RequestEvent) error {
+			tenantID := e.Request.PathValue("tenantId")
+
+			buf := new(bytes.Buffer)
+			if _, err := exportTenantArchive(e.App, tenantID, buf); err != nil {
+				return e.BadRequestError("导出租户失败", err)
+			}
+
+			e.Response.Header().Set("Content-Type", "application/zip")
+			e.Response.Header().Set("Content-Disposition", "attachment; filename=\"tenant_"+tenantID+".zip\"")
+			_, _ = e.Response.Write(buf.Bytes())
+			return nil
+		}).BindFunc(auth.RBAC("system:tenant:export"))
+
+		se.Router.POST("/api/system/tenant/archive/restore", func(e *core.RequestEvent) error {
+			file, _, err := e.Request.FormFile("file")
+			if err != nil {
+				return e.BadRequestError("缺少上传文件字段 file", err)
+			}
+			defer file.Close()
+
+			data, err := io.ReadAll(file)
+			if err != nil {
+				return e.BadRequestError("读取归档失败", err)
+			}
+
+			report, err := restoreTenantArchive(e.App, data, e.Request.FormValue("tenant_id"))
+			if err != nil {
+				return e.BadRequestError("恢复租户失败："+err.Error(), nil)
+			}
+			return tools.JSONSuccess(e, report)
+		}).BindFunc(auth.RBAC("system:tenant:add"))
+
+		return se.Next()
+	})
+}
+
+// exportTenantArchive 将租户记录、所有含租户字段的集合中该租户的记录、引用这些记录的关联表
+// 以及记录的上传文件写入 zip 归档。
+func exportTenantArchive(app core.App, tenantID string, w io.Writer) (*tenantArchiveManifest, error) {
+	if _, err := app.FindRecordById("tenant", tenantID); err != nil {
+		return nil, fmt.Errorf("租户 %s 不存在", tenantID)
+	}
+
+	scoped, relations, err := tenantPurgePlan(app)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		return nil, err
+	}
+	defer fsys.Close()
+
+	zw := zip.NewWriter(w)
+	manifest := &tenantArchiveManifest{
+		Version:    tenantArchiveVersion,
+		TenantID:   tenantID,
+		ExportedAt: types.NowDateTime(),
+	}
+
+	writeRows := func(c *core.Collection, rows []archiveRow) error {
+		manifest.Collections = append(manifest.Collections, tenantArchiveCollection{Name: c.Name, Count: len(rows)})
+		if err := writeArchiveJSON(zw, archiveRecordsDir+c.Name+".json", rows); err != nil {
+			return err
+		}
+		n, err := writeArchiveFiles(zw, fsys, c, rows)
+		manifest.Files += n
+		return err
+	}
+
+	tenantColl, err := app.FindCachedCollectionByNameOrId("tenant")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := selectArchiveRows(app, tenantColl.Name, dbx.HashExp{"id": tenantID})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeRows(tenantColl, rows); err != nil {
+		return nil, err
+	}
+
+	for _, c := range scoped {
+		field := tools.GetDataScopeFields(c).Tenant
+		rows, err := selectArchiveRows(app, c.Name, field.ExpressionIn(tenantID))
+		if err != nil {
+			return nil, err
+		}
+		if err := writeRows(c, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	// 关联表：同一集合可能通过多个字段引用租户数据，按 id 去重
+	joinRows := map[string][]archiveRow{}
+	joinColls := map[string]*core.Collection{}
+	seen := map[string]struct{}{}
+	order := []string{}
+	for _, rel := range relations {
+		targetTenant := tools.GetDataScopeFields(rel.target).Tenant
+		exp := rel.field.ExpressionInQuery(
+			fmt.Sprintf("SELECT [[id]] FROM {{%s}} WHERE [[%s]] = {:archiveTenant}", rel.target.Name, targetTenant.Name),
+			dbx.Params{"archiveTenant": tenantID},
+		)
+		rows, err := selectArchiveRows(app, rel.collection.Name, exp)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := joinColls[rel.collection.Name]; !ok {
+			joinColls[rel.collection.Name] = rel.collection
+			order = append(order, rel.collection.Name)
+		}
+		for _, row := range rows {
+			key := rel.collection.Name + "/" + fmt.Sprint(row["id"])
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			joinRows[rel.collection.Name] = append(joinRows[rel.collection.Name], row)
+		}
+	}
+	for _, name := range order {
+		if err := writeRows(joinColls[name], joinRows[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writeArchiveJSON(zw, archiveManifestName, manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// selectArchiveRows 以原始列值读取记录（包含密码哈希等隐藏字段）
+func selectArchiveRows(app core.App, table string, where dbx.Expression) ([]archiveRow, error) {
+	raw := []dbx.NullStringMap{}
+	if err := app.DB().Select("*").From(table).Where(where).All(&raw); err != nil {
+		return nil, err
+	}
+	rows := make([]archiveRow, 0, len(raw))
+	for _, r := range raw {
+		row := archiveRow{}
+		for k, v := range r {
+			if v.Valid {
+				row[k] = v.String
+			} else {
+				row[k] = nil
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func writeArchiveJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeArchiveFiles 写入记录的上传文件：files/{collection}/{recordId}/{filename}
+func writeArchiveFiles(zw *zip.Writer, fsys *filesystem.System, c *core.Collection, rows []archiveRow) (int, error) {
+	fileFields := archiveFileFields(c)
+	if len(fileFields) == 0 {
+		return 0, nil
+	}
+
+	count := 0
+	for _, row := range rows {
+		id := fmt.Sprint(row["id"])
+		for _, field := range fileFields {
+			for _, name := range archiveListValue(row[field]) {
+				r, err := fsys.GetReader(c.BaseFilesPath() + "/" + id + "/" + name)
+				if err != nil {
+					continue // 文件缺失时跳过
+				}
+				f, err := zw.Create(archiveFilesDir + c.Name + "/" + id + "/" + name)
+				if err == nil {
+					_, err = io.Copy(f, r)
+				}
+				r.Close()
+				if err != nil {
+					return count, err
+				}
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func archiveFileFields(c *core.Collection) []string {
+	names := []string{}
+	for _, f := range c.Fields {
+		if f.Type() == core.FieldTypeFile {
+			names = append(names, f.GetName())
+		}
+	}
+	return names
+}
+
+// archiveListValue 解析单值或多值字段的原始值（单个值或 JSON 数组，如文件名、关联ID）
+func archiveListValue(v any) []string {
+	s, _ := v.(string)
+	if s == "" {
+		return nil
+	}
+	var names []string
+	if strings.HasPrefix(s, "[") && json.Unmarshal([]byte(s), &names) == nil {
+		return names
+	}
+	return []string{s}
+}
+
+// tenantRestoreReport 恢复结果
+type tenantRestoreReport struct {
+	TenantID    string                    `json:"tenant_id"`
+	Collections []tenantArchiveCollection `json:"collections"`
+	Files       int                       `json:"files"`
+}
+
+// restoreTenantArchive 在一个事务中恢复归档：所有记录重新生成ID，relation 字段、
+// archiveSoftRefs 中的字段与 dept.ancestors 随之重映射，租户字段一律写为目标租户。
+// 只接受 tenantArchiveCollections 中的集合，并拒绝 validateArchiveRecords 检出的越权记录与无效文件名。
+// 目标租户已存在或任一记录违反唯一约束（如用户名重复）时整体回滚。
+func restoreTenantArchive(app core.App, data []byte, targetTenantID string) (*tenantRestoreReport, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("无效的归档文件: %w", err)
+	}
+
+	manifest := tenantArchiveManifest{}
+	if err := readArchiveJSON(zr, archiveManifestName, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Version != tenantArchiveVersion {
+		return nil, fmt.Errorf("不支持的归档版本 %d", manifest.Version)
+	}
+
+	if targetTenantID == "" {
+		targetTenantID = manifest.TenantID
+	}
+	if exists, _ := app.FindRecordById("tenant", targetTenantID); exists != nil {
+		return nil, fmt.Errorf("租户 %s 已存在", targetTenantID)
+	}
+
+	// 只允许恢复租户记录、含租户字段的集合及其关联表（与租户清理范围一致）
+	allowed, err := tenantArchiveCollections(app)
+	if err != nil {
+		return nil, err
+	}
+
+	// 读取记录并为每条记录分配新ID
+	records := map[string][]archiveRow{}
+	idMaps := map[string]map[string]string{}
+	collections := map[string]*core.Collection{}
+	collNames := map[string]string{} // 集合ID -> 集合名
+	for _, mc := range manifest.Collections {
+		c, ok := allowed[mc.Name]
+		if !ok {
+			return nil, fmt.Errorf("归档包含不允许恢复的集合 %s", mc.Name)
+		}
+		if _, ok := collections[mc.Name]; ok {
+			return nil, fmt.Errorf("归档中集合 %s 重复", mc.Name)
+		}
+		rows := []archiveRow{}
+		if err := readArchiveJSON(zr, archiveRecordsDir+mc.Name+".json", &rows); err != nil {
+			return nil, err
+		}
+		collections[mc.Name] = c
+		collNames[c.Id] = c.Name
+		records[mc.Name] = rows
+
+		ids := map[string]string{}
+		for _, row := range rows {
+			oldID := fmt.Sprint(row["id"])
+			if c.Name == "tenant" {
+				ids[oldID] = targetTenantID
+			} else {
+				ids[oldID] = core.GenerateDefaultRandomId()
+			}
+		}
+		idMaps[mc.Name] = ids
+	}
+	if len(records["tenant"]) != 1 {
+		return nil, fmt.Errorf("归档中应包含且仅包含一条租户记录")
+	}
+	if err := validateArchiveRecords(app, collections, collNames, records, idMaps); err != nil {
+		return nil, err
+	}
+
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		return nil, err
+	}
+	defer fsys.Close()
+
+	report := &tenantRestoreReport{TenantID: targetTenantID}
+	uploaded := []string{}
+
+	err = app.RunInTransaction(func(txApp core.App) error {
+		for _, mc := range manifest.Collections {
+			c := collections[mc.Name]
+			tenantField := tools.GetDataScopeFields(c).Tenant
+
+			for _, row := range records[mc.Name] {
+				oldID := fmt.Sprint(row["id"])
+				params := dbx.Params{}
+				for _, f := range c.Fields {
+					name := f.GetName()
+					v, ok := row[name]
+					if !ok {
+						continue
+					}
+					params[name] = remapArchiveValue(c, f, v, collNames, idMaps)
+				}
+				params["id"] = idMaps[mc.Name][oldID]
+				if !tenantField.IsZero() {
+					// 租户字段一律写为目标租户，不信任归档中的值
+					params[tenantField.Name] = archiveTenantValue(tenantField, targetTenantID)
+				}
+				if c.IsAuth() {
+					// 令牌密钥需唯一，恢复的账号重新生成（原会话失效）
+					params[core.FieldNameTokenKey] = security.RandomString(50)
+				}
+
+				if _, err := txApp.DB().Insert(c.Name, params).Execute(); err != nil {
+					return fmt.Errorf("恢复 %s 记录 %s 冲突: %w", c.Name, oldID, err)
+				}
+
+				for _, field := range archiveFileFields(c) {
+					for _, name := range archiveListValue(row[field]) {
+						if !validArchiveFileName(name) {
+							return fmt.Errorf("%s 记录 %s 的文件名 %q 无效", c.Name, oldID, name)
+						}
+						content, err := readArchiveFile(zr, archiveFilesDir+c.Name+"/"+oldID+"/"+name)
+						if err != nil {
+							continue // 归档中无此文件
+						}
+						key := c.BaseFilesPath() + "/" + params["id"].(string) + "/" + name
+						if err := fsys.Upload(content, key); err != nil {
+							return err
+						}
+						uploaded = append(uploaded, key)
+						report.Files++
+					}
+				}
+			}
+			report.Collections = append(report.Collections, tenantArchiveCollection{Name: c.Name, Count: len(records[mc.Name])})
+		}
+		return nil
+	})
+	if err != nil {
+		for _, key := range uploaded {
+			_ = fsys.Delete(key)
+		}
+		return nil, err
+	}
+
+	tools.InvalidateDataScopeCache()
+	return report, nil
+}
+
+// remapArchiveValue 将原始列值中的记录ID替换为新ID（租户字段由调用方统一改写）
+func remapArchiveValue(
+	c *core.Collection,
+	f core.Field,
+	v any,
+	collNames map[string]string,
+	idMaps map[string]map[string]string,
+) any {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return v
+	}
+
+	name := f.GetName()
+
+	if rf, ok := f.(*core.RelationField); ok {
+		// 引用的集合不在归档中（如 menu）时保留原值
+		target, ok := collNames[rf.CollectionId]
+		if !ok {
+			return s
+		}
+		if rf.IsMultiple() {
+			return remapJSONIDs(s, idMaps[target])
+		}
+		return remapID(s, idMaps[target])
+	}
+
+	if c.Name == "dept" && name == "ancestors" {
+		parts := strings.Split(s, ",")
+		for i, p := range parts {
+			parts[i] = remapID(strings.TrimSpace(p), idMaps["dept"])
+		}
+		return strings.Join(parts, ",")
+	}
+
+	if target, ok := archiveSoftRefs[name]; ok {
+		return remapID(s, idMaps[target])
+	}
+
+	return s
+}
+
+// tenantArchiveCollections 允许恢复的集合：tenant、含租户字段的集合及引用它们的关联表
+func tenantArchiveCollections(app core.App) (map[string]*core.Collection, error) {
+	scoped, relations, err := tenantPurgePlan(app)
+	if err != nil {
+		return nil, err
+	}
+	tenantColl, err := app.FindCachedCollectionByNameOrId("tenant")
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := map[string]*core.Collection{tenantColl.Name: tenantColl}
+	for _, c := range scoped {
+		allowed[c.Name] = c
+	}
+	for _, rel := range relations {
+		allowed[rel.collection.Name] = rel.collection
+	}
+	return allowed, nil
+}
+
+// validateArchiveRecords 拒绝可能越权的记录：
+//   - 超级管理员角色（role_key=superadmin 不区分租户）；
+//   - 指向租户或租户集合、但不在归档中且已存在的记录（如其他租户的角色）。
+func validateArchiveRecords(
+	app core.App,
+	collections map[string]*core.Collection,
+	collNames map[string]string,
+	records map[string][]archiveRow,
+	idMaps map[string]map[string]string,
+) error {
+	for _, row := range records["role"] {
+		if fmt.Sprint(row["role_key"]) == "superadmin" {
+			return fmt.Errorf("归档不能包含超级管理员角色")
+		}
+	}
+
+	for name, c := range collections {
+		for _, f := range c.Fields {
+			rf, ok := f.(*core.RelationField)
+			if !ok {
+				continue
+			}
+			target, err := app.FindCachedCollectionByNameOrId(rf.CollectionId)
+			if err != nil {
+				continue
+			}
+			if target.Name != "tenant" && tools.GetDataScopeFields(target).Tenant.IsZero() {
+				continue // 非租户数据（如 menu）保留原引用
+			}
+			for _, row := range records[name] {
+				for _, id := range archiveListValue(row[rf.Name]) {
+					if _, ok := idMaps[collNames[target.Id]][id]; ok {
+						continue
+					}
+					if _, err := app.FindRecordById(target.Name, id); err == nil {
+						return fmt.Errorf("%s 记录 %v 引用了归档外的 %s 记录 %s", name, row["id"], target.Name, id)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// archiveTenantValue 租户字段的恢复值（多值字段为 JSON 数组）
+func archiveTenantValue(field tools.DataScopeField, tenantID string) string {
+	if field.Multi {
+		raw, _ := json.Marshal([]string{tenantID})
+		return string(raw)
+	}
+	return tenantID
+}
+
+// validArchiveFileName 文件名不能包含路径分隔符或 ..，避免写到记录目录之外
+func validArchiveFileName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`) && !strings.Contains(name, "..")
+}
+
+func remapID(id string, ids map[string]string) string {
+	if n, ok := ids[id]; ok {
+		return n
+	}
+	return id
+}
+
+func remapJSONIDs(raw string, ids map[string]string) string {
+	var list []string
+	if err := json.Unmarshal([]byte(raw), &list); err != nil {
+		return remapID(raw, ids)
+	}
+	for i, id := range list {
+		list[i] = remapID(id, ids)
+	}
+	b, _ := json.Marshal(list)
+	return string(b)
+}
+
+func readArchiveJSON(zr *zip.Reader, name string, v any) error {
+	content, err := readArchiveFile(zr, name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+func readArchiveFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(path.Clean(name))
+	if err != nil {
+		return nil, fmt.Errorf("归档缺少 %s", name)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
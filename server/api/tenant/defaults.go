@@ -0,0 +1,472 @@
+package tenant
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"pocketbase-ruoyi/api/auth"
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/routine"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// defaultSyncCollections 从默认租户克隆并保持同步的集合
+var defaultSyncCollections = []string{"dict_type", "dict_data", "config"}
+
+// defaultSyncExclude 不参与同步比较与复制的字段
+var defaultSyncExclude = map[string]struct{}{
+	"id": {}, "tenant_id": {}, "source_id": {}, "overridden": {},
+	"create_dept": {}, "create_by": {}, "create_time": {},
+	"update_by": {}, "update_time": {},
+}
+
+// 同步动作
+const (
+	defaultSyncCreate   = "create"   // 租户缺少该行，新建
+	defaultSyncUpdate   = "update"   // 未覆盖的行与默认值不一致，更新
+	defaultSyncLink     = "link"     // 按业务键找到未关联的行，建立关联（内容不同则视为已覆盖）
+	defaultSyncDelete   = "delete"   // 默认行已删除，删除未覆盖的关联行
+	defaultSyncOverride = "override" // 租户已覆盖，跳过
+	defaultSyncReset    = "reset"    // 重置为默认值
+)
+
+// defaultSyncItem 单行同步结果
+type defaultSyncItem struct {
+	Collection string `json:"collection"`
+	Action     string `json:"action"`
+	Key        string `json:"key"`
+	SourceID   string `json:"source_id,omitempty"`
+	TargetID   string `json:"target_id,omitempty"`
+}
+
+// defaultSyncReport 单个租户的同步结果
+type defaultSyncReport struct {
+	TenantID string            `json:"tenant_id"`
+	Counts   map[string]int    `json:"counts"`
+	Items    []defaultSyncItem `json:"items"`
+}
+
+func newDefaultSyncReport(tenantID string) *defaultSyncReport {
+	return &defaultSyncReport{TenantID: tenantID, Counts: map[string]int{}, Items: []defaultSyncItem{}}
+}
+
+func (r *defaultSyncReport) add(item defaultSyncItem) {
+	r.Counts[item.Action]++
+	r.Items = append(r.Items, item)
+}
+
+// defaultSyncOptions 同步选项
+type defaultSyncOptions struct {
+	DryRun        bool // 仅预览，不写入
+	Reset         bool // 覆盖租户的修改并恢复为默认值
+	CreateMissing bool // 租户缺少的行是否新建
+}
+
+// registerTenantDefaultsSync 注册默认租户字典/参数配置的同步：
+//   - 克隆的行通过 source_id 关联默认租户的来源行；租户通过接口修改后标记为 overridden；
+//   - 默认租户新增/修改/删除时，自动同步到所有未覆盖该行的租户（新增/修改在后台逐个租户按事务同步）；
+//   - GET /api/system/tenant/syncTenantDict/propagations 查询最近的后台同步结果及失败的租户；
+//   - GET /api/system/tenant/syncTenantDict/preview?tenantId= 预览同步结果（不传 tenantId 为全部租户）；
+//   - GET /api/system/tenant/syncTenantDict?tenantId= 执行同步（补齐缺失行、关联历史数据）；
+//   - POST /api/system/tenant/resetDefault/{tenantId} 将租户的字典与参数配置重置为默认值。
+func registerTenantDefaultsSync(app *pocketbase.PocketBase) {
+	for _, name := range defaultSyncCollections {
+		app.OnRecordUpdateRequest(name).BindFunc(markDefaultOverridden)
+
+		app.OnRecordAfterCreateSuccess(name).BindFunc(func(e *core.RecordEvent) error {
+			propagateDefaultRecord(e.App, e.Record, true)
+			return e.Next()
+		})
+		app.OnRecordAfterUpdateSuccess(name).BindFunc(func(e *core.RecordEvent) error {
+			propagateDefaultRecord(e.App, e.Record, false)
+			return e.Next()
+		})
+		app.OnRecordAfterDeleteSuccess(name).BindFunc(func(e *core.RecordEvent) error {
+			if e.Record.GetString("tenant_id") == defaultTenantID {
+				if err := deleteLinkedDefaultRows(e.App, e.Record); err != nil {
+					e.App.Logger().Error("同步删除默认数据失败", "collection", e.Record.Collection().Name, "id", e.Record.Id, "error", err)
+				}
+			}
+			return e.Next()
+		})
+	}
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/system/tenant/syncTenantDict/preview", func(e *core.RequestEvent) error {
+			reports, err := syncDefaultsForRequest(e, defaultSyncOptions{DryRun: true, CreateMissing: true})
+			if err != nil {
+				return err
+			}
+			return tools.JSONSuccess(e, reports)
+		}).BindFunc(auth.RBAC("system:tenant:edit"))
+
+		se.Router.GET("/api/system/tenant/syncTenantDict", func(e *core.RequestEvent) error {
+			reports, err := syncDefaultsForRequest(e, defaultSyncOptions{CreateMissing: true})
+			if err != nil {
+				return err
+			}
+			return tools.JSONSuccess(e, reports)
+		}).BindFunc(auth.RBAC("system:tenant:edit"))
+
+		se.Router.GET("/api/system/tenant/syncTenantDict/propagations", func(e *core.RequestEvent) error {
+			return tools.JSONSuccess(e, recentDefaultPropagations())
+		}).BindFunc(auth.RBAC("system:tenant:edit"))
+
+		se.Router.POST("/api/system/tenant/resetDefault/{tenantId}", func(e *core.RequestEvent) error {
+			tenantID := e.Request.PathValue("tenantId")
+			if tenantID == defaultTenantID {
+				return e.BadRequestError("默认租户无需重置", nil)
+			}
+			if _, err := e.App.FindRecordById("tenant", tenantID); err != nil {
+				return e.NotFoundError("租户不存在", err)
+			}
+
+			var report *defaultSyncReport
+			err := e.App.RunInTransaction(func(txApp core.App) error {
+				var err error
+				report, err = syncTenantDefaults(txApp, tenantID, defaultSyncOptions{Reset: true, CreateMissing: true})
+				return err
+			})
+			if err != nil {
+				return e.BadRequestError("重置失败："+err.Error(), nil)
+			}
+			return tools.JSONSuccess(e, report)
+		}).BindFunc(auth.RBAC("system:tenant:edit"))
+
+		return se.Next()
+	})
+}
+
+// syncDefaultsForRequest 按 tenantId 参数同步单个租户，未指定时同步所有租户
+func syncDefaultsForRequest(e *core.RequestEvent, opts defaultSyncOptions) ([]*defaultSyncReport, error) {
+	tenantIDs := []string{}
+	if tenantID := strings.TrimSpace(e.Request.URL.Query().Get("tenantId")); tenantID != "" {
+		if tenantID == defaultTenantID {
+			return nil, apis.NewBadRequestError("默认租户无需同步", nil)
+		}
+		if _, err := e.App.FindRecordById("tenant", tenantID); err != nil {
+			return nil, apis.NewNotFoundError("租户不存在", err)
+		}
+		tenantIDs = append(tenantIDs, tenantID)
+	} else {
+		tenantIDs = syncTargetTenants(e.App)
+	}
+
+	reports := []*defaultSyncReport{}
+	err := e.App.RunInTransaction(func(txApp core.App) error {
+		for _, tenantID := range tenantIDs {
+			report, err := syncTenantDefaults(txApp, tenantID, opts)
+			if err != nil {
+				return fmt.Errorf("租户 %s：%w", tenantID, err)
+			}
+			reports = append(reports, report)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, apis.NewBadRequestError("同步失败："+err.Error(), nil)
+	}
+	return reports, nil
+}
+
+// syncTargetTenants 需要同步默认数据的租户（未删除的非默认租户）
+func syncTargetTenants(app core.App) []string {
+	ids := []string{}
+	_ = app.DB().Select("id").From("tenant").
+		Where(dbx.Not(dbx.HashExp{"id": defaultTenantID})).
+		AndWhere(dbx.Not(dbx.HashExp{"del_flag": tenantDelFlagDeleted})).
+		OrderBy("id ASC").
+		Column(&ids)
+	return ids
+}
+
+// syncTenantDefaults 同步默认租户的所有字典与参数配置到 tenantID
+func syncTenantDefaults(app core.App, tenantID string, opts defaultSyncOptions) (*defaultSyncReport, error) {
+	report := newDefaultSyncReport(tenantID)
+	for _, name := range defaultSyncCollections {
+		sources, err := app.FindRecordsByFilter(name, "tenant_id={:tid}", "", 0, 0, dbx.Params{"tid": defaultTenantID})
+		if err != nil {
+			return nil, err
+		}
+		if err := syncTenantCollection(app, name, tenantID, sources, true, opts, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// defaultPropagationRecentMax 保留的最近同步任务数
+const defaultPropagationRecentMax = 50
+
+// defaultPropagationFailure 单个租户的同步失败
+type defaultPropagationFailure struct {
+	TenantID string `json:"tenant_id"`
+	Error    string `json:"error"`
+}
+
+// defaultPropagation 默认行新增/修改后同步到各租户的后台任务结果
+type defaultPropagation struct {
+	Collection string                      `json:"collection"`
+	SourceID   string                      `json:"source_id"`
+	Tenants    int                         `json:"tenants"`
+	Failed     []defaultPropagationFailure `json:"failed"`
+	StartedAt  types.DateTime              `json:"started_at"`
+	FinishedAt types.DateTime              `json:"finished_at"`
+}
+
+// defaultPropagations 最近的同步任务（供接口查询）；runMu 保证同一时间只有一个任务写入租户数据
+var defaultPropagations = struct {
+	runMu  sync.Mutex
+	mu     sync.Mutex
+	recent []*defaultPropagation
+}{}
+
+// propagateDefaultRecord 默认租户的行新增/修改后，在后台同步到所有租户
+func propagateDefaultRecord(app core.App, src *core.Record, created bool) {
+	if src.GetString("tenant_id") != defaultTenantID {
+		return
+	}
+	src = src.Fresh()
+	routine.FireAndForget(func() {
+		runDefaultPropagation(app, src, created)
+	})
+}
+
+// runDefaultPropagation 逐个租户在独立事务中同步 src，失败的租户回滚并记录在结果中
+func runDefaultPropagation(app core.App, src *core.Record, created bool) *defaultPropagation {
+	defaultPropagations.runMu.Lock()
+	defer defaultPropagations.runMu.Unlock()
+
+	name := src.Collection().Name
+	result := &defaultPropagation{
+		Collection: name,
+		SourceID:   src.Id,
+		Failed:     []defaultPropagationFailure{},
+		StartedAt:  types.NowDateTime(),
+	}
+	tenantIDs := syncTargetTenants(app)
+	result.Tenants = len(tenantIDs)
+	for _, tenantID := range tenantIDs {
+		err := app.RunInTransaction(func(txApp core.App) error {
+			report := newDefaultSyncReport(tenantID)
+			// 修改时不重建租户自行删除的行
+			return syncTenantCollection(txApp, name, tenantID, []*core.Record{src}, false, defaultSyncOptions{CreateMissing: created}, report)
+		})
+		if err != nil {
+			app.Logger().Error("同步默认数据失败", "collection", name, "id", src.Id, "tenant", tenantID, "error", err)
+			result.Failed = append(result.Failed, defaultPropagationFailure{TenantID: tenantID, Error: err.Error()})
+		}
+	}
+	result.FinishedAt = types.NowDateTime()
+	if len(result.Failed) > 0 {
+		app.Logger().Warn("默认数据同步部分失败", "collection", name, "id", src.Id, "tenants", result.Tenants, "failed", len(result.Failed))
+	}
+
+	defaultPropagations.mu.Lock()
+	defaultPropagations.recent = append(defaultPropagations.recent, result)
+	if len(defaultPropagations.recent) > defaultPropagationRecentMax {
+		defaultPropagations.recent = defaultPropagations.recent[len(defaultPropagations.recent)-defaultPropagationRecentMax:]
+	}
+	defaultPropagations.mu.Unlock()
+
+	return result
+}
+
+// recentDefaultPropagations 返回最近的同步任务，最新的在前
+func recentDefaultPropagations() []*defaultPropagation {
+	defaultPropagations.mu.Lock()
+	defer defaultPropagations.mu.Unlock()
+
+	out := make([]*defaultPropagation, len(defaultPropagations.recent))
+	for i, p := range defaultPropagations.recent {
+		out[len(out)-1-i] = p
+	}
+	return out
+}
+
+// deleteLinkedDefaultRows 默认行删除后，删除各租户未覆盖的关联行
+func deleteLinkedDefaultRows(app core.App, src *core.Record) error {
+	rows, err := app.FindRecordsByFilter(src.Collection().Name, "source_id={:sid} && overridden=false", "", 0, 0, dbx.Params{"sid": src.Id})
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := app.Delete(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncTenantCollection 将 sources 同步到租户的 collName 集合。
+// full 为 true 时 sources 为默认租户的全部行，来源已不存在的关联行会被删除。
+func syncTenantCollection(app core.App, collName, tenantID string, sources []*core.Record, full bool, opts defaultSyncOptions, report *defaultSyncReport) error {
+	coll, err := app.FindCollectionByNameOrId(collName)
+	if err != nil {
+		return err
+	}
+	if coll.Fields.GetByName("source_id") == nil {
+		return nil
+	}
+
+	targets, err := app.FindRecordsByFilter(collName, "tenant_id={:tid}", "", 0, 0, dbx.Params{"tid": tenantID})
+	if err != nil {
+		return err
+	}
+	bySource := map[string]*core.Record{}
+	byKey := map[string]*core.Record{}
+	for _, t := range targets {
+		if sid := t.GetString("source_id"); sid != "" {
+			bySource[sid] = t
+		} else {
+			byKey[defaultRowKey(collName, t)] = t
+		}
+	}
+
+	sourceIDs := map[string]struct{}{}
+	for _, src := range sources {
+		sourceIDs[src.Id] = struct{}{}
+		item := defaultSyncItem{Collection: collName, Key: defaultRowKey(collName, src), SourceID: src.Id}
+
+		target, linked := bySource[src.Id]
+		if !linked {
+			target = byKey[item.Key]
+		}
+
+		switch {
+		case target == nil:
+			if !opts.CreateMissing {
+				continue
+			}
+			item.Action = defaultSyncCreate
+			if !opts.DryRun {
+				nr := core.NewRecord(coll)
+				copyDefaultFields(coll, src, nr)
+				nr.Set("tenant_id", tenantID)
+				nr.Set("source_id", src.Id)
+				if err := app.Save(nr); err != nil {
+					return err
+				}
+				item.TargetID = nr.Id
+			}
+
+		case !linked:
+			// 历史数据（克隆时未记录来源）：按业务键关联，内容不同视为租户已覆盖
+			item.TargetID = target.Id
+			item.Action = defaultSyncLink
+			overridden := defaultFieldsDiffer(coll, src, target)
+			if opts.Reset && overridden {
+				item.Action, overridden = defaultSyncReset, false
+				copyDefaultFields(coll, src, target)
+			}
+			if !opts.DryRun {
+				target.Set("source_id", src.Id)
+				target.Set("overridden", overridden)
+				if err := app.Save(target); err != nil {
+					return err
+				}
+			}
+
+		case target.GetBool("overridden") && !opts.Reset:
+			item.TargetID = target.Id
+			item.Action = defaultSyncOverride
+
+		case defaultFieldsDiffer(coll, src, target) || target.GetBool("overridden"):
+			item.TargetID = target.Id
+			item.Action = defaultSyncUpdate
+			if opts.Reset {
+				item.Action = defaultSyncReset
+			}
+			if !opts.DryRun {
+				copyDefaultFields(coll, src, target)
+				target.Set("overridden", false)
+				if err := app.Save(target); err != nil {
+					return err
+				}
+			}
+
+		default:
+			continue
+		}
+
+		report.add(item)
+	}
+
+	if !full {
+		return nil
+	}
+
+	// 来源行已删除：删除未覆盖的关联行
+	for sid, target := range bySource {
+		if _, ok := sourceIDs[sid]; ok || target.GetBool("overridden") {
+			continue
+		}
+		report.add(defaultSyncItem{
+			Collection: collName,
+			Action:     defaultSyncDelete,
+			Key:        defaultRowKey(collName, target),
+			SourceID:   sid,
+			TargetID:   target.Id,
+		})
+		if !opts.DryRun {
+			if err := app.Delete(target); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// markDefaultOverridden 租户通过接口修改了来自默认租户的行时，标记为已覆盖，之后不再被同步
+func markDefaultOverridden(e *core.RecordRequestEvent) error {
+	if e.Record.GetString("tenant_id") != defaultTenantID && e.Record.GetString("source_id") != "" {
+		if defaultFieldsDiffer(e.Collection, e.Record.Original(), e.Record) {
+			e.Record.Set("overridden", true)
+		}
+	}
+	return e.Next()
+}
+
+// defaultRowKey 行的业务键，用于关联未记录来源的历史数据
+func defaultRowKey(collName string, r *core.Record) string {
+	switch collName {
+	case "config":
+		return r.GetString("key")
+	case "dict_type":
+		return r.GetString("dict_type")
+	case "dict_data":
+		return r.GetString("dict_type") + ":" + r.GetString("dict_value")
+	default:
+		return r.Id
+	}
+}
+
+// copyDefaultFields 复制参与同步的字段
+func copyDefaultFields(coll *core.Collection, src, dst *core.Record) {
+	for _, f := range coll.Fields {
+		if _, skip := defaultSyncExclude[f.GetName()]; skip || f.Type() == core.FieldTypeAutodate {
+			continue
+		}
+		dst.Set(f.GetName(), src.Get(f.GetName()))
+	}
+}
+
+// defaultFieldsDiffer 参与同步的字段是否存在差异
+func defaultFieldsDiffer(coll *core.Collection, a, b *core.Record) bool {
+	for _, f := range coll.Fields {
+		if _, skip := defaultSyncExclude[f.GetName()]; skip || f.Type() == core.FieldTypeAutodate {
+			continue
+		}
+		if fmt.Sprint(a.Get(f.GetName())) != fmt.Sprint(b.Get(f.GetName())) {
+			return true
+		}
+	}
+	return false
+}
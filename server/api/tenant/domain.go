@@ -0,0 +1,194 @@
+package tenant
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// tenantDomainsCacheKey 域名 -> 租户ID 映射的缓存键；租户变更时立即失效
+const tenantDomainsCacheKey = "tenant_domains"
+
+// tenantOption 登录页租户下拉选项
+type tenantOption struct {
+	ID          string `db:"id" json:"id"`
+	CompanyName string `db:"company_name" json:"company_name"`
+	Domain      string `db:"domain" json:"domain"`
+	Intro       string `db:"intro" json:"intro"`
+}
+
+// registerTenantDomain 注册按请求域名（Host）解析租户：
+//   - 全局中间件把匹配 tenant.domain 的租户写入请求上下文（tools.GetDomainTenant），
+//     匿名访问含租户字段的公开集合时按该租户过滤；
+//   - 登录时校验账号属于域名对应的租户（超级管理员除外）；
+//   - GET /api/auth/tenant/list 返回租户开关与可选租户，域名匹配时只返回该租户并预选。
+//
+// 注意：需在数据权限中间件（auth.RegisterDataScope）之前注册。
+func registerTenantDomain(app *pocketbase.PocketBase) {
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.BindFunc(func(e *core.RequestEvent) error {
+			if tenantID := resolveTenantByHost(e.App, e.Request.Host); tenantID != "" {
+				tools.SetDomainTenant(e, tenantID)
+			}
+			return e.Next()
+		})
+
+		se.Router.GET("/api/auth/tenant/list", func(e *core.RequestEvent) error {
+			if !isTenantEnabled(e.App) {
+				return tools.JSONSuccess(e, map[string]any{
+					"tenantEnabled": false,
+					"tenantId":      "",
+					"list":          []tenantOption{},
+				})
+			}
+
+			list, err := selectableTenants(e.App, tools.GetDomainTenant(e))
+			if err != nil {
+				return e.InternalServerError("获取租户列表失败", err)
+			}
+
+			// 仅当域名匹配且租户可用时预选
+			tenantID := ""
+			if domainTenantID := tools.GetDomainTenant(e); domainTenantID != "" && len(list) == 1 && list[0].ID == domainTenantID {
+				tenantID = domainTenantID
+			}
+
+			return tools.JSONSuccess(e, map[string]any{
+				"tenantEnabled": true,
+				"tenantId":      tenantID,
+				"list":          list,
+			})
+		})
+
+		return se.Next()
+	})
+
+	// 登录时账号须属于域名对应的租户
+	app.OnRecordAuthRequest("users").BindFunc(func(e *core.RecordAuthRequestEvent) error {
+		domainTenantID := tools.GetDomainTenant(e.RequestEvent)
+		if e.Record == nil || domainTenantID == "" || e.Record.GetString("tenant_id") == domainTenantID {
+			return e.Next()
+		}
+		if tools.IsRoleSuperuser(app, e.Record.Id) {
+			return e.Next()
+		}
+		return apis.NewBadRequestError("该账号不属于当前域名对应的租户", nil)
+	})
+
+	// 租户变更后清理域名映射缓存
+	clearDomains := func(e *core.RecordEvent) error {
+		tools.CacheDelete(tenantDomainsCacheKey)
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("tenant").BindFunc(clearDomains)
+	app.OnRecordAfterUpdateSuccess("tenant").BindFunc(clearDomains)
+	app.OnRecordAfterDeleteSuccess("tenant").BindFunc(clearDomains)
+}
+
+// resolveTenantByHost 按请求 Host 查找绑定的租户（先匹配带端口的完整 Host，再匹配主机名）
+func resolveTenantByHost(app core.App, host string) string {
+	host = normalizeDomain(host)
+	if host == "" {
+		return ""
+	}
+
+	domains := tenantDomains(app)
+	if tenantID, ok := domains[host]; ok {
+		return tenantID
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return domains[hostname]
+	}
+	return ""
+}
+
+// tenantDomains 读取未删除租户的域名映射（带缓存）
+func tenantDomains(app core.App) map[string]string {
+	if v, ok := tools.CacheGetValue(tenantDomainsCacheKey); ok {
+		if domains, ok := v.(map[string]string); ok {
+			return domains
+		}
+	}
+
+	rows := []tenantOption{}
+	err := app.DB().Select("id", "domain").From("tenant").
+		Where(dbx.NewExp("domain != ''")).
+		AndWhere(dbx.Not(dbx.HashExp{"del_flag": tenantDelFlagDeleted})).
+		All(&rows)
+	if err != nil {
+		return map[string]string{}
+	}
+
+	domains := make(map[string]string, len(rows))
+	for _, r := range rows {
+		if d := normalizeDomain(r.Domain); d != "" {
+			domains[d] = r.ID
+		}
+	}
+
+	tools.CacheSetValue(tenantDomainsCacheKey, domains, tenantStatusCacheTTL)
+	return domains
+}
+
+// normalizeDomain 统一域名格式：去掉协议、路径与结尾的点，转小写
+func normalizeDomain(domain string) string {
+	d := strings.ToLower(strings.TrimSpace(domain))
+	if i := strings.Index(d, "://"); i >= 0 {
+		d = d[i+3:]
+	}
+	if i := strings.IndexAny(d, "/?#"); i >= 0 {
+		d = d[:i]
+	}
+	return strings.TrimSuffix(d, ".")
+}
+
+// selectableTenants 返回登录可选的租户（正常、未删除、未过期，默认租户不校验到期）；
+// domainTenantID 不为空时只返回该租户。
+func selectableTenants(app core.App, domainTenantID string) ([]tenantOption, error) {
+	q := app.DB().Select("id", "company_name", "domain", "intro").From("tenant").
+		Where(dbx.HashExp{"status": tenantStatusNormal}).
+		AndWhere(dbx.Not(dbx.HashExp{"del_flag": tenantDelFlagDeleted})).
+		AndWhere(dbx.NewExp("(id = {:default} OR expire_time = '' OR expire_time IS NULL OR expire_time > {:now})", dbx.Params{
+			"default": defaultTenantID,
+			"now":     types.NowDateTime().String(),
+		})).
+		OrderBy("id ASC")
+	if domainTenantID != "" {
+		q.AndWhere(dbx.HashExp{"id": domainTenantID})
+	}
+
+	list := []tenantOption{}
+	if err := q.All(&list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// isTenantEnabled 读取全局配置 tenantEnabled（global_config.key），未配置时视为启用
+func isTenantEnabled(app core.App) bool {
+	rec, err := app.FindFirstRecordByFilter("global_config", "key = 'tenantEnabled'")
+	if err != nil {
+		return true
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(rec.GetString("value")), &v); err != nil {
+		return true
+	}
+	switch val := v.(type) {
+	case bool:
+		return val
+	case string:
+		return val == "true" || val == "Y" || val == "1"
+	default:
+		return true
+	}
+}
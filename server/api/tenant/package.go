@@ -0,0 +1,228 @@
+package tenant
+
+import (
+	"strings"
+
+	"pocketbase-ruoyi/api/auth"
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// roleMenuSync 单个角色的菜单同步结果
+type roleMenuSync struct {
+	RoleID  string   `json:"role_id"`
+	RoleKey string   `json:"role_key"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// tenantMenuSync 租户的菜单同步结果
+type tenantMenuSync struct {
+	TenantID  string         `json:"tenant_id"`
+	PackageID string         `json:"package_id"`
+	Roles     []roleMenuSync `json:"roles"`
+}
+
+// registerTenantPackageSync 注册套餐变更联动：
+//   - 编辑套餐时，同步所有绑定该套餐的租户；
+//   - 租户更换套餐时，同步该租户；
+//   - GET /api/system/tenant/syncTenantPackage?tenantId=&packageId= 手动为租户指定套餐并同步；
+//   - 角色只能分配套餐内的菜单（角色编辑见 system.checkRoleMenusInPackage）。
+//
+// 同步时租户管理员角色（role_key=admin）的菜单与套餐保持一致，其他角色移除套餐外的菜单；
+// 同步结果通过响应记录的 menu_sync 字段（或接口 data）返回。
+func registerTenantPackageSync(app *pocketbase.PocketBase) {
+	app.OnRecordUpdateRequest("tenant_package").BindFunc(func(e *core.RecordRequestEvent) error {
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			original := e.App
+			e.App = txApp
+			defer func() { e.App = original }()
+
+			if err := e.Next(); err != nil {
+				return err
+			}
+
+			tenants, err := txApp.FindRecordsByFilter("tenant", "package_id={:pid}", "", 0, 0, dbx.Params{"pid": e.Record.Id})
+			if err != nil {
+				return err
+			}
+
+			results := []tenantMenuSync{}
+			for _, t := range tenants {
+				result, err := syncTenantPackageMenus(txApp, t.Id, e.Record)
+				if err != nil {
+					return err
+				}
+				results = append(results, result)
+			}
+
+			e.Record.WithCustomData(true)
+			e.Record.Set("menu_sync", results)
+			return nil
+		})
+	})
+
+	app.OnRecordUpdateRequest("tenant").BindFunc(func(e *core.RecordRequestEvent) error {
+		oldPackageID := tenantPackageID(e.Record.Original())
+
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			original := e.App
+			e.App = txApp
+			defer func() { e.App = original }()
+
+			if err := e.Next(); err != nil {
+				return err
+			}
+
+			pkgID := tenantPackageID(e.Record)
+			if pkgID == oldPackageID || pkgID == "" {
+				return nil
+			}
+			pkg, err := txApp.FindRecordById("tenant_package", pkgID)
+			if err != nil {
+				return apis.NewBadRequestError("租户套餐不存在", err)
+			}
+
+			result, err := syncTenantPackageMenus(txApp, e.Record.Id, pkg)
+			if err != nil {
+				return err
+			}
+
+			e.Record.WithCustomData(true)
+			e.Record.Set("menu_sync", result)
+			return nil
+		})
+	})
+
+	// 直接新增 role_menu 时同样不允许超出套餐
+	app.OnRecordCreateRequest("role_menu").BindFunc(func(e *core.RecordRequestEvent) error {
+		role, err := e.App.FindRecordById("role", e.Record.GetString("role"))
+		if err != nil {
+			return e.Next()
+		}
+		if allowed, restricted := tools.TenantPackageMenus(e.App, role.GetString("tenant_id")); restricted {
+			if _, ok := allowed[e.Record.GetString("menu")]; !ok {
+				return e.BadRequestError("该菜单不在租户套餐内，无法分配", nil)
+			}
+		}
+		return e.Next()
+	})
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/system/tenant/syncTenantPackage", func(e *core.RequestEvent) error {
+			tenantID := strings.TrimSpace(e.Request.URL.Query().Get("tenantId"))
+			pkgID := strings.TrimSpace(e.Request.URL.Query().Get("packageId"))
+			if tenantID == "" || pkgID == "" {
+				return e.BadRequestError("缺少租户ID或套餐ID", nil)
+			}
+			if tenantID == defaultTenantID {
+				return e.BadRequestError("默认租户不使用套餐", nil)
+			}
+
+			var result tenantMenuSync
+			err := e.App.RunInTransaction(func(txApp core.App) error {
+				tenant, err := txApp.FindRecordById("tenant", tenantID)
+				if err != nil {
+					return apis.NewNotFoundError("租户不存在", err)
+				}
+				pkg, err := txApp.FindRecordById("tenant_package", pkgID)
+				if err != nil {
+					return apis.NewNotFoundError("租户套餐不存在", err)
+				}
+
+				if tenantPackageID(tenant) != pkg.Id {
+					tenant.Set("package_id", pkg.Id)
+					if err := txApp.Save(tenant); err != nil {
+						return err
+					}
+				}
+
+				result, err = syncTenantPackageMenus(txApp, tenant.Id, pkg)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			return tools.JSONSuccess(e, result)
+		}).BindFunc(auth.RBAC("system:tenant:edit"))
+
+		return se.Next()
+	})
+}
+
+// tenantPackageID 返回租户绑定的套餐ID（package_id 为数字字段，0 表示未绑定）
+func tenantPackageID(tenant *core.Record) string {
+	if id := tenant.GetString("package_id"); id != "0" {
+		return id
+	}
+	return ""
+}
+
+// syncTenantPackageMenus 按套餐重算租户内所有角色的菜单：
+// 管理员角色补齐并裁剪为套餐菜单，其他角色仅移除套餐外的菜单。
+func syncTenantPackageMenus(app core.App, tenantID string, pkg *core.Record) (tenantMenuSync, error) {
+	result := tenantMenuSync{TenantID: tenantID, PackageID: pkg.Id, Roles: []roleMenuSync{}}
+	if tenantID == defaultTenantID {
+		return result, nil
+	}
+
+	pkgMenus := pkg.GetStringSlice("menu_ids")
+	allowed := make(map[string]struct{}, len(pkgMenus))
+	for _, id := range pkgMenus {
+		allowed[id] = struct{}{}
+	}
+
+	roles, err := app.FindRecordsByFilter("role", "tenant_id={:tid}", "role_sort", 0, 0, dbx.Params{"tid": tenantID})
+	if err != nil {
+		return result, err
+	}
+
+	for _, role := range roles {
+		change := roleMenuSync{RoleID: role.Id, RoleKey: role.GetString("role_key")}
+
+		links, err := app.FindRecordsByFilter("role_menu", "role={:rid}", "", 0, 0, dbx.Params{"rid": role.Id})
+		if err != nil {
+			return result, err
+		}
+
+		current := make(map[string]struct{}, len(links))
+		for _, link := range links {
+			menuID := link.GetString("menu")
+			if _, ok := allowed[menuID]; ok {
+				current[menuID] = struct{}{}
+				continue
+			}
+			if err := app.Delete(link); err != nil {
+				return result, err
+			}
+			change.Removed = append(change.Removed, menuID)
+		}
+
+		if change.RoleKey == "admin" {
+			for _, menuID := range pkgMenus {
+				if _, ok := current[menuID]; ok || menuID == "" {
+					continue
+				}
+				if err := createJoinRecord(app, "role_menu", func(jr *core.Record) {
+					jr.Set("role", role.Id)
+					jr.Set("menu", menuID)
+				}); err != nil {
+					return result, err
+				}
+				current[menuID] = struct{}{}
+				change.Added = append(change.Added, menuID)
+			}
+		}
+
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			result.Roles = append(result.Roles, change)
+		}
+	}
+
+	return result, nil
+}
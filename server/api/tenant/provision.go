@@ -0,0 +1,419 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"pocketbase-ruoyi/api/auth"
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// 初始化步骤的执行结果
+const (
+	provisionCreated = "created" // 本次新建
+	provisionExists  = "exists"  // 已存在，未改动
+	provisionSkipped = "skipped" // 缺少必要信息，未执行
+)
+
+// provisionStep 单个初始化步骤的结果
+type provisionStep struct {
+	Step   string `json:"step"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Count  int    `json:"count,omitempty"`
+	Msg    string `json:"msg,omitempty"`
+}
+
+// provisionReport 租户初始化报告
+type provisionReport struct {
+	TenantID string          `json:"tenant_id"`
+	Steps    []provisionStep `json:"steps"`
+}
+
+func (r *provisionReport) add(step provisionStep) {
+	r.Steps = append(r.Steps, step)
+}
+
+// tenantAdminAccount 租户管理员账号信息（仅在需要新建管理员用户时使用）
+type tenantAdminAccount struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	NickName string `json:"nick_name"`
+	Phone    string `json:"phonenumber"`
+}
+
+// registerTenantProvision 注册租户初始化：创建租户时在同一事务中完成初始化，
+// 并提供修复接口 POST /api/system/tenant/repair/{tenantId} 用于补全初始化不完整的租户。
+func registerTenantProvision(app *pocketbase.PocketBase) {
+	app.OnRecordCreateRequest("tenant").BindFunc(provisionOnTenantCreate)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST("/api/system/tenant/repair/{tenantId}", func(e *core.RequestEvent) error {
+			tenantID := e.Request.PathValue("tenantId")
+
+			var admin tenantAdminAccount
+			if e.Request.ContentLength > 0 {
+				if err := e.BindBody(&admin); err != nil {
+					return e.BadRequestError("无效的请求体", err)
+				}
+			}
+
+			var report *provisionReport
+			err := e.App.RunInTransaction(func(txApp core.App) error {
+				tenant, err := txApp.FindRecordById("tenant", tenantID)
+				if err != nil {
+					return apis.NewNotFoundError("租户不存在", err)
+				}
+
+				if admin.NickName == "" {
+					admin.NickName = tenant.GetString("contact_user_name")
+				}
+				if admin.Phone == "" {
+					admin.Phone = tenant.GetString("contact_phone")
+				}
+
+				report, err = provisionTenant(txApp, tenant, admin)
+				return err
+			})
+			if err != nil {
+				return tenantProvisionError(err)
+			}
+
+			return tools.JSONSuccess(e, report)
+		}).BindFunc(auth.RBAC("system:tenant:edit"))
+
+		return se.Next()
+	})
+}
+
+// provisionOnTenantCreate 在同一事务中保存租户并完成初始化，任一步骤失败则整体回滚；
+// 初始化报告通过租户记录的 provision 字段返回。
+func provisionOnTenantCreate(e *core.RecordRequestEvent) error {
+	req, _, _ := tools.ParseBody[tenantCreateRequest](e.Request)
+	admin := tenantAdminAccount{
+		Username: req.Username,
+		Password: req.Password,
+		NickName: req.ContactUserName,
+		Phone:    req.ContactPhone,
+	}
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		original := e.App
+		e.App = txApp
+		defer func() { e.App = original }()
+
+		if err := e.Next(); err != nil {
+			return err
+		}
+
+		report, err := provisionTenant(txApp, e.Record, admin)
+		if err != nil {
+			return tenantProvisionError(err)
+		}
+
+		// 响应在事务提交后才写出，此处附加的报告会一并返回
+		e.Record.WithCustomData(true)
+		e.Record.Set("provision", report)
+		return nil
+	})
+}
+
+func tenantProvisionError(err error) error {
+	var apiErr *router.ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return apis.NewBadRequestError("租户初始化失败："+err.Error(), nil)
+}
+
+// provisionTenant 初始化（或补全）租户的管理员角色、根部门、管理员用户及其关联，
+// 并克隆默认租户的字典与配置。每一步都会先检查是否已存在，因此可重复执行。
+// 需在事务中调用：任一步骤返回错误时由调用方回滚。
+func provisionTenant(app core.App, tenant *core.Record, admin tenantAdminAccount) (*provisionReport, error) {
+	tenantID := tenant.Id
+	report := &provisionReport{TenantID: tenantID}
+
+	// 1) 租户管理员角色（基于套餐的菜单）
+	roleID, step, err := ensureTenantAdminRole(app, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("创建管理员角色失败: %w", err)
+	}
+	report.add(step)
+
+	// 2) 根部门：公司名作为部门名称
+	deptID, step, err := ensureTenantRootDept(app, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("创建根部门失败: %w", err)
+	}
+	report.add(step)
+
+	// 3) 角色与部门关联
+	step, err = ensureJoinRecord(app, "role_dept", "role_dept", map[string]string{"role": roleID, "dept": deptID})
+	if err != nil {
+		return nil, fmt.Errorf("创建角色部门关联失败: %w", err)
+	}
+	report.add(step)
+
+	// 4) 管理员用户
+	userID, step, err := ensureTenantAdminUser(app, tenantID, roleID, deptID, admin)
+	if err != nil {
+		return nil, fmt.Errorf("创建管理员用户失败: %w", err)
+	}
+	report.add(step)
+
+	if userID != "" {
+		// 5) 部门负责人
+		step, err = ensureDeptLeader(app, deptID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("设置部门负责人失败: %w", err)
+		}
+		report.add(step)
+
+		// 6) 用户-角色关联
+		step, err = ensureJoinRecord(app, "user_role", "user_role", map[string]string{"user": userID, "role": roleID})
+		if err != nil {
+			return nil, fmt.Errorf("创建用户角色关联失败: %w", err)
+		}
+		report.add(step)
+	}
+
+	// 7) 克隆默认租户字典与配置
+	for _, collName := range []string{"dict_type", "dict_data", "config"} {
+		step, err = ensureTenantClone(app, collName, defaultTenantID, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("克隆 %s 失败: %w", collName, err)
+		}
+		report.add(step)
+	}
+
+	return report, nil
+}
+
+// ensureTenantAdminRole 查找或创建租户管理员角色（role_key=admin）及其套餐菜单
+func ensureTenantAdminRole(app core.App, tenant *core.Record) (string, provisionStep, error) {
+	step := provisionStep{Step: "role"}
+
+	if role, _ := app.FindFirstRecordByFilter("role", "tenant_id={:tid} && role_key='admin'", dbx.Params{"tid": tenant.Id}); role != nil {
+		step.Status, step.ID = provisionExists, role.Id
+		return role.Id, step, nil
+	}
+
+	coll, err := app.FindCollectionByNameOrId("role")
+	if err != nil {
+		return "", step, err
+	}
+	nr := core.NewRecord(coll)
+	nr.Set("id", core.GenerateDefaultRandomId())
+	nr.Set("tenant_id", tenant.Id)
+	nr.Set("role_name", "租户管理员")
+	nr.Set("role_key", "admin")
+	nr.Set("role_sort", 1)
+	nr.Set("status", "0") // 正常
+	if err := app.Save(nr); err != nil {
+		return "", step, err
+	}
+
+	// 套餐菜单；无套餐信息时仍然创建一个空角色
+	menuIDs := []string{}
+	if pkgID := tenantPackageID(tenant); pkgID != "" {
+		if pkg, err := app.FindRecordById("tenant_package", pkgID); err == nil {
+			menuIDs = pkg.GetStringSlice("menu_ids")
+		}
+	}
+	for _, mid := range menuIDs {
+		if mid == "" {
+			continue
+		}
+		if err := createJoinRecord(app, "role_menu", func(jr *core.Record) {
+			jr.Set("role", nr.Id)
+			jr.Set("menu", mid)
+		}); err != nil {
+			return "", step, err
+		}
+		step.Count++
+	}
+
+	step.Status, step.ID = provisionCreated, nr.Id
+	return nr.Id, step, nil
+}
+
+// ensureTenantRootDept 查找或创建租户根部门（无父级）
+func ensureTenantRootDept(app core.App, tenant *core.Record) (string, provisionStep, error) {
+	step := provisionStep{Step: "dept"}
+
+	if dept, _ := app.FindFirstRecordByFilter("dept", "tenant_id={:tid} && (parent_id='' || parent_id='0')", dbx.Params{"tid": tenant.Id}); dept != nil {
+		step.Status, step.ID = provisionExists, dept.Id
+		return dept.Id, step, nil
+	}
+
+	coll, err := app.FindCollectionByNameOrId("dept")
+	if err != nil {
+		return "", step, err
+	}
+	nr := core.NewRecord(coll)
+	nr.Set("id", core.GenerateDefaultRandomId())
+	nr.Set("tenant_id", tenant.Id)
+	nr.Set("dept_name", guessCompanyName(tenant))
+	nr.Set("status", "0") // 正常
+	// parent_id 为 relation 字段，根部门留空（部门树与 ancestors 计算均按根节点处理）
+	if err := app.Save(nr); err != nil {
+		return "", step, err
+	}
+	// ancestors 将由 dept 钩子自动纠正
+
+	step.Status, step.ID = provisionCreated, nr.Id
+	return nr.Id, step, nil
+}
+
+// ensureTenantAdminUser 查找或创建租户管理员用户。
+// 已有绑定管理员角色的用户或 user_type=admin 的用户时直接复用；否则需要提供账号与密码。
+func ensureTenantAdminUser(app core.App, tenantID, roleID, deptID string, admin tenantAdminAccount) (string, provisionStep, error) {
+	step := provisionStep{Step: "user"}
+
+	if userID := findTenantAdminUser(app, tenantID, roleID); userID != "" {
+		step.Status, step.ID = provisionExists, userID
+		return userID, step, nil
+	}
+
+	if strings.TrimSpace(admin.Username) == "" || admin.Password == "" {
+		step.Status, step.Msg = provisionSkipped, "缺少管理员账号或密码"
+		return "", step, nil
+	}
+
+	// 检测user_name重名
+	if record, _ := app.FindFirstRecordByFilter("users", "user_name={:uname}", dbx.Params{"uname": admin.Username}); record != nil {
+		return "", step, fmt.Errorf("用户名 %s 已存在", admin.Username)
+	}
+
+	coll, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		return "", step, err
+	}
+	nr := core.NewRecord(coll)
+	nr.Set("tenant_id", tenantID)
+	nr.Set("user_name", admin.Username)
+	nr.Set("user_type", "admin")
+	nr.Set("nick_name", admin.NickName)
+	nr.Set("phonenumber", admin.Phone)
+	nr.Set("dept_id", deptID)
+	nr.Set("status", "0") // 正常
+	nr.SetPassword(admin.Password)
+	if err := app.Save(nr); err != nil {
+		return "", step, err
+	}
+
+	step.Status, step.ID = provisionCreated, nr.Id
+	return nr.Id, step, nil
+}
+
+// findTenantAdminUser 查找租户管理员用户：优先绑定管理员角色（roleID 为空时按 role_key=admin 查找）的用户，
+// 其次 user_type=admin 的用户；未找到时返回空
+func findTenantAdminUser(app core.App, tenantID, roleID string) string {
+	if roleID == "" {
+		_ = app.DB().Select("id").From("role").
+			Where(dbx.HashExp{"tenant_id": tenantID, "role_key": "admin"}).
+			Limit(1).
+			Row(&roleID)
+	}
+
+	userID := ""
+	if roleID != "" {
+		_ = app.DB().Select("users.id").From("users").
+			InnerJoin("user_role", dbx.NewExp("user_role.user = users.id")).
+			Where(dbx.HashExp{"users.tenant_id": tenantID, "user_role.role": roleID}).
+			Limit(1).
+			Row(&userID)
+	}
+	if userID == "" {
+		_ = app.DB().Select("id").From("users").
+			Where(dbx.HashExp{"tenant_id": tenantID, "user_type": "admin"}).
+			Limit(1).
+			Row(&userID)
+	}
+	return userID
+}
+
+// ensureDeptLeader 部门未设置负责人时设为管理员用户（若存在 leader 字段）
+func ensureDeptLeader(app core.App, deptID, userID string) (provisionStep, error) {
+	step := provisionStep{Step: "dept_leader", ID: deptID}
+
+	if !hasField(app, "dept", "leader") {
+		step.Status = provisionSkipped
+		return step, nil
+	}
+
+	dept, err := app.FindRecordById("dept", deptID)
+	if err != nil {
+		return step, err
+	}
+	if dept.GetString("leader") != "" {
+		step.Status = provisionExists
+		return step, nil
+	}
+
+	dept.Set("leader", userID)
+	if err := app.Save(dept); err != nil {
+		return step, err
+	}
+	step.Status = provisionCreated
+	return step, nil
+}
+
+// ensureJoinRecord 关联记录不存在时创建
+func ensureJoinRecord(app core.App, stepName, collName string, values map[string]string) (provisionStep, error) {
+	step := provisionStep{Step: stepName}
+
+	filters := make([]string, 0, len(values))
+	params := dbx.Params{}
+	for k, v := range values {
+		filters = append(filters, fmt.Sprintf("%s={:%s}", k, k))
+		params[k] = v
+	}
+	if existing, _ := app.FindFirstRecordByFilter(collName, strings.Join(filters, " && "), params); existing != nil {
+		step.Status, step.ID = provisionExists, existing.Id
+		return step, nil
+	}
+
+	coll, err := app.FindCollectionByNameOrId(collName)
+	if err != nil {
+		return step, err
+	}
+	nr := core.NewRecord(coll)
+	for k, v := range values {
+		nr.Set(k, v)
+	}
+	if err := app.Save(nr); err != nil {
+		return step, err
+	}
+	step.Status, step.ID = provisionCreated, nr.Id
+	return step, nil
+}
+
+// ensureTenantClone 租户下没有该集合的数据时，从默认租户克隆
+func ensureTenantClone(app core.App, collName, sourceTenantID, targetTenantID string) (provisionStep, error) {
+	step := provisionStep{Step: collName}
+
+	count := 0
+	err := app.DB().Select("count(*)").From(collName).Where(dbx.HashExp{"tenant_id": targetTenantID}).Row(&count)
+	if err != nil {
+		return step, err
+	}
+	if count > 0 {
+		step.Status, step.Count = provisionExists, count
+		return step, nil
+	}
+
+	cloned, err := cloneByTenant(app, collName, sourceTenantID, targetTenantID)
+	if err != nil {
+		return step, err
+	}
+	step.Status, step.Count = provisionCreated, cloned
+	return step, nil
+}
@@ -0,0 +1,422 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"pocketbase-ruoyi/api/auth"
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/routine"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 清理任务状态
+const (
+	purgeRunning = "running"
+	purgeDone    = "done"
+	purgeFailed  = "failed"
+)
+
+// tenantPurgedContextKey 清除任务删除租户记录时写入上下文的标记
+type tenantPurgedContextKey struct{}
+
+// purgeBatchSize 逐条删除（含文件/认证集合）时每批读取的记录数
+const purgeBatchSize = 200
+
+// tenantPurgeItem 单个集合的清理结果
+type tenantPurgeItem struct {
+	Collection string `json:"collection"`
+	Deleted    int64  `json:"deleted"`
+	Msg        string `json:"msg,omitempty"`
+}
+
+// tenantPurgeProgress 租户清理进度（保存在 app.Store 中，可通过接口查询）
+type tenantPurgeProgress struct {
+	mu sync.Mutex
+
+	TenantID    string            `json:"tenant_id"`
+	Status      string            `json:"status"`
+	StartedAt   types.DateTime    `json:"started_at"`
+	FinishedAt  types.DateTime    `json:"finished_at"`
+	Collections []tenantPurgeItem `json:"collections"`
+	Error       string            `json:"error,omitempty"`
+}
+
+func (p *tenantPurgeProgress) add(item tenantPurgeItem) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Collections = append(p.Collections, item)
+}
+
+func (p *tenantPurgeProgress) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.FinishedAt = types.NowDateTime()
+	if err != nil {
+		p.Status = purgeFailed
+		p.Error = err.Error()
+		return
+	}
+	p.Status = purgeDone
+}
+
+// snapshot 返回进度副本，供接口序列化
+func (p *tenantPurgeProgress) snapshot() map[string]any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return map[string]any{
+		"tenant_id":   p.TenantID,
+		"status":      p.Status,
+		"started_at":  p.StartedAt,
+		"finished_at": p.FinishedAt,
+		"collections": append([]tenantPurgeItem(nil), p.Collections...),
+		"error":       p.Error,
+	}
+}
+
+func tenantPurgeStoreKey(tenantID string) string {
+	return "tenant_purge_" + tenantID
+}
+
+// registerTenantPurge 注册租户删除与清理：
+//   - 删除租户请求改为软删除（del_flag=1，记录 delete_time），在保留期内可恢复；
+//   - 每日任务彻底清除超过保留期的租户数据（所有含 tenant_id 的集合、其关联表及文件）；
+//   - POST /api/system/tenant/purge/{tenantId} 立即清除已软删除的租户，GET 同路径查询进度。
+func registerTenantPurge(app *pocketbase.PocketBase) {
+	app.OnRecordDeleteRequest("tenant").BindFunc(softDeleteTenant)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.POST("/api/system/tenant/purge/{tenantId}", func(e *core.RequestEvent) error {
+			tenant, err := e.App.FindRecordById("tenant", e.Request.PathValue("tenantId"))
+			if err != nil {
+				return e.NotFoundError("租户不存在", err)
+			}
+			if tenant.GetString("del_flag") != tenantDelFlagDeleted {
+				return e.BadRequestError("请先删除租户后再清除数据", nil)
+			}
+
+			progress, ok := claimTenantPurge(e.App, tenant.Id)
+			if !ok {
+				return e.BadRequestError("该租户的清除任务正在执行", nil)
+			}
+			routine.FireAndForget(func() {
+				runTenantPurge(e.App, progress)
+			})
+			return tools.JSONSuccess(e, progress.snapshot())
+		}).BindFunc(auth.RBAC("system:tenant:remove"))
+
+		se.Router.GET("/api/system/tenant/purge/{tenantId}", func(e *core.RequestEvent) error {
+			progress, ok := e.App.Store().Get(tenantPurgeStoreKey(e.Request.PathValue("tenantId"))).(*tenantPurgeProgress)
+			if !ok {
+				return e.NotFoundError("没有该租户的清除记录", nil)
+			}
+			return tools.JSONSuccess(e, progress.snapshot())
+		}).BindFunc(auth.RBAC("system:tenant:remove"))
+
+		return se.Next()
+	})
+
+	cfg := loadTenantConfig()
+	err := app.Cron().Add("tenantPurge", cfg.PurgeCron, func() {
+		purgeDeletedTenants(app, cfg.PurgeRetentionDays)
+	})
+	if err != nil {
+		app.Logger().Error("租户清理任务注册失败", "cron", cfg.PurgeCron, "error", err)
+	}
+}
+
+// softDeleteTenant 删除租户时仅标记删除并停用，同时使其用户会话失效
+func softDeleteTenant(e *core.RecordRequestEvent) error {
+	if e.Record.Id == defaultTenantID {
+		return e.BadRequestError("默认租户不允许删除", nil)
+	}
+
+	e.Record.Set("del_flag", tenantDelFlagDeleted)
+	e.Record.Set("status", tenantStatusDisabled)
+	if hasField(e.App, "tenant", "delete_time") {
+		e.Record.Set("delete_time", types.NowDateTime())
+	}
+	if err := e.App.Save(e.Record); err != nil {
+		return e.BadRequestError("删除租户失败", err)
+	}
+
+	var userIDs []string
+	_ = e.App.DB().Select("id").From("users").Where(dbx.HashExp{"tenant_id": e.Record.Id}).Column(&userIDs)
+	for _, userID := range userIDs {
+		_ = tools.RevokeUserSessions(e.App, userID)
+	}
+
+	return e.NoContent(204)
+}
+
+// purgeDeletedTenants 清除软删除超过 retentionDays 天的租户
+func purgeDeletedTenants(app core.App, retentionDays int) {
+	deletedAtField := "update_time"
+	if hasField(app, "tenant", "delete_time") {
+		deletedAtField = "delete_time"
+	}
+	before, _ := types.ParseDateTime(time.Now().AddDate(0, 0, -retentionDays))
+
+	var tenantIDs []string
+	err := app.DB().Select("id").From("tenant").
+		Where(dbx.HashExp{"del_flag": tenantDelFlagDeleted}).
+		AndWhere(dbx.NewExp("[["+deletedAtField+"]] != '' AND [["+deletedAtField+"]] <= {:before}", dbx.Params{"before": before})).
+		AndWhere(dbx.Not(dbx.HashExp{"id": defaultTenantID})).
+		Column(&tenantIDs)
+	if err != nil {
+		app.Logger().Error("查询待清除租户失败", "error", err)
+		return
+	}
+
+	for _, tid := range tenantIDs {
+		// 定时任务中逐个同步执行，避免同时占用写连接
+		if progress, ok := claimTenantPurge(app, tid); ok {
+			runTenantPurge(app, progress)
+		}
+	}
+}
+
+// claimTenantPurge 登记一个新的清除任务；同一租户已有任务在执行时返回 ok=false
+func claimTenantPurge(app core.App, tenantID string) (*tenantPurgeProgress, bool) {
+	progress := &tenantPurgeProgress{
+		TenantID:  tenantID,
+		Status:    purgeRunning,
+		StartedAt: types.NowDateTime(),
+	}
+
+	claimed := false
+	app.Store().SetFunc(tenantPurgeStoreKey(tenantID), func(old any) any {
+		if p, ok := old.(*tenantPurgeProgress); ok {
+			p.mu.Lock()
+			running := p.Status == purgeRunning
+			p.mu.Unlock()
+			if running {
+				return old
+			}
+		}
+		claimed = true
+		return progress
+	})
+
+	return progress, claimed
+}
+
+// runTenantPurge 执行清除并记录结果
+func runTenantPurge(app core.App, progress *tenantPurgeProgress) {
+	err := purgeTenant(app, progress.TenantID, progress)
+	if err != nil {
+		app.Logger().Error("租户清除失败", "tenant", progress.TenantID, "error", err)
+	} else {
+		app.Logger().Info("租户已清除", "tenant", progress.TenantID)
+	}
+	progress.finish(err)
+}
+
+// purgeTenant 清除租户的全部数据后删除租户记录本身
+func purgeTenant(app core.App, tenantID string, progress *tenantPurgeProgress) error {
+	if tenantID == defaultTenantID {
+		return fmt.Errorf("默认租户不允许删除")
+	}
+
+	if err := purgeTenantData(app, tenantID, progress); err != nil {
+		return err
+	}
+
+	tenant, err := app.FindRecordById("tenant", tenantID)
+	if err != nil {
+		return nil // 已被删除
+	}
+	// 数据已清理完毕，标记上下文使 afterTenantDeleted 不再重复清理
+	ctx := context.WithValue(context.Background(), tenantPurgedContextKey{}, true)
+	if err := app.DeleteWithContext(ctx, tenant); err != nil {
+		return err
+	}
+	progress.add(tenantPurgeItem{Collection: "tenant", Deleted: 1})
+	return nil
+}
+
+// tenantRelation 不含租户字段、通过关联字段引用租户数据的集合（如 user_role）
+type tenantRelation struct {
+	collection *core.Collection
+	field      tools.DataScopeField
+	target     *core.Collection
+}
+
+// tenantPurgePlan 找出所有按租户隔离的集合（含 tenant_id 或 data_scope_fields.yml 中映射的租户字段），
+// 以及引用这些集合的关联表。
+func tenantPurgePlan(app core.App) ([]*core.Collection, []tenantRelation, error) {
+	all, err := app.FindAllCollections(core.CollectionTypeBase, core.CollectionTypeAuth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scoped := []*core.Collection{}
+	scopedIDs := map[string]*core.Collection{}
+	for _, c := range all {
+		if c.System || c.Name == "tenant" {
+			continue
+		}
+		if tools.GetDataScopeFields(c).Tenant.IsZero() {
+			continue
+		}
+		scoped = append(scoped, c)
+		scopedIDs[c.Id] = c
+	}
+
+	relations := []tenantRelation{}
+	for _, c := range all {
+		if c.System || c.Name == "tenant" {
+			continue
+		}
+		if _, ok := scopedIDs[c.Id]; ok {
+			continue
+		}
+		for _, f := range c.Fields {
+			rf, ok := f.(*core.RelationField)
+			if !ok {
+				continue
+			}
+			if target, ok := scopedIDs[rf.CollectionId]; ok {
+				relations = append(relations, tenantRelation{
+					collection: c,
+					field:      tools.DataScopeField{Name: rf.Name, Multi: rf.IsMultiple()},
+					target:     target,
+				})
+			}
+		}
+	}
+
+	return scoped, relations, nil
+}
+
+// purgeTenantData 删除租户在所有租户集合中的数据：
+// 先清理引用租户数据的关联表，再删除租户集合记录。含文件字段或认证集合逐条删除，
+// 以便同时删除存储的文件与登录信息；其余集合批量删除。可重复执行。
+func purgeTenantData(app core.App, tenantID string, progress *tenantPurgeProgress) error {
+	if tenantID == "" || tenantID == defaultTenantID {
+		return nil
+	}
+
+	scoped, relations, err := tenantPurgePlan(app)
+	if err != nil {
+		return err
+	}
+
+	// 1) 关联表
+	for _, rel := range relations {
+		item, err := purgeTenantRelation(app, rel, tenantID)
+		if err != nil {
+			return fmt.Errorf("清理 %s.%s 失败: %w", rel.collection.Name, rel.field.Name, err)
+		}
+		progress.add(item)
+	}
+
+	// 2) 租户集合
+	for _, c := range scoped {
+		field := tools.GetDataScopeFields(c).Tenant
+		if field.Multi {
+			// 多租户共享的记录不做删除
+			progress.add(tenantPurgeItem{Collection: c.Name, Msg: "租户字段为多值，已跳过"})
+			continue
+		}
+
+		var deleted int64
+		if c.IsAuth() || hasFileField(c) {
+			deleted, err = deleteTenantRecordsOneByOne(app, c, field.Name, tenantID)
+		} else {
+			var res interface{ RowsAffected() (int64, error) }
+			res, err = app.DB().Delete(c.Name, dbx.HashExp{field.Name: tenantID}).Execute()
+			if err == nil {
+				deleted, _ = res.RowsAffected()
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("清理 %s 失败: %w", c.Name, err)
+		}
+		progress.add(tenantPurgeItem{Collection: c.Name, Deleted: deleted})
+	}
+
+	return nil
+}
+
+// purgeTenantRelation 清理关联表中引用租户数据的记录。单值关联直接删除整行；
+// 多值关联仅删除全部引用都属于该租户的记录，其余记录只移除指向该租户数据的ID，
+// 避免误删仍引用其他租户（或默认租户）数据的记录。
+func purgeTenantRelation(app core.App, rel tenantRelation, tenantID string) (tenantPurgeItem, error) {
+	item := tenantPurgeItem{Collection: rel.collection.Name + "." + rel.field.Name}
+
+	targetTenant := tools.GetDataScopeFields(rel.target).Tenant
+	purgedIDs := fmt.Sprintf("SELECT [[id]] FROM {{%s}} WHERE [[%s]] = {:purgeTenant}", rel.target.Name, targetTenant.Name)
+	params := dbx.Params{"purgeTenant": tenantID}
+
+	if !rel.field.Multi {
+		res, err := app.DB().Delete(rel.collection.Name, rel.field.ExpressionInQuery(purgedIDs, params)).Execute()
+		if err != nil {
+			return item, err
+		}
+		item.Deleted, _ = res.RowsAffected()
+		return item, nil
+	}
+
+	values := fmt.Sprintf("json_each(CASE WHEN json_valid([[%s]]) THEN [[%s]] ELSE '[]' END)", rel.field.Name, rel.field.Name)
+	references := rel.field.ExpressionInQuery(purgedIDs, params)
+	onlyPurged := dbx.NewExp(fmt.Sprintf(
+		"NOT EXISTS (SELECT 1 FROM %s WHERE json_each.value NOT IN (%s))", values, purgedIDs,
+	), params)
+
+	res, err := app.DB().Delete(rel.collection.Name, dbx.And(references, onlyPurged)).Execute()
+	if err != nil {
+		return item, err
+	}
+	item.Deleted, _ = res.RowsAffected()
+
+	remaining := dbx.NewExp(fmt.Sprintf(
+		"(SELECT json_group_array(json_each.value) FROM %s WHERE json_each.value NOT IN (%s))", values, purgedIDs,
+	), params)
+	res, err = app.DB().Update(rel.collection.Name, dbx.Params{rel.field.Name: remaining}, references).Execute()
+	if err != nil {
+		return item, err
+	}
+	if updated, _ := res.RowsAffected(); updated > 0 {
+		item.Msg = fmt.Sprintf("%d 条记录仍引用其他租户数据，仅移除了该租户的关联ID", updated)
+	}
+	return item, nil
+}
+
+// deleteTenantRecordsOneByOne 逐条删除记录（触发文件清理与记录钩子）
+func deleteTenantRecordsOneByOne(app core.App, c *core.Collection, tenantField, tenantID string) (int64, error) {
+	var deleted int64
+	for {
+		records, err := app.FindRecordsByFilter(c, tenantField+"={:tid}", "", purgeBatchSize, 0, dbx.Params{"tid": tenantID})
+		if err != nil {
+			return deleted, err
+		}
+		if len(records) == 0 {
+			return deleted, nil
+		}
+		for _, r := range records {
+			if err := app.Delete(r); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+}
+
+func hasFileField(c *core.Collection) bool {
+	for _, f := range c.Fields {
+		if f.Type() == core.FieldTypeFile {
+			return true
+		}
+	}
+	return false
+}
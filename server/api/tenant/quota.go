@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// registerTenantQuota 注册租户账号数量（account_count）限制：
+// 任何途径创建用户（记录 API、Excel 导入、租户初始化等）都会校验配额，-1 表示不限制。
+func registerTenantQuota(app *pocketbase.PocketBase) {
+	app.OnRecordCreateExecute("users").BindFunc(checkAccountQuotaOnCreate)
+
+	// 租户列表返回已用账号数 account_used
+	app.OnRecordEnrich("tenant").BindFunc(func(e *core.RecordEnrichEvent) error {
+		e.Record.WithCustomData(true)
+		e.Record.Set("account_used", countTenantAccounts(e.App, e.Record.Id))
+		return e.Next()
+	})
+}
+
+// checkAccountQuotaOnCreate 在插入用户的同一事务中统计并校验配额。
+// 写事务独占 SQLite 的写连接，并发创建会被串行化，因此统计结果不会被其他请求穿透。
+func checkAccountQuotaOnCreate(e *core.RecordEvent) error {
+	tenantID := e.Record.GetString("tenant_id")
+	if tenantID == "" {
+		return e.Next()
+	}
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		if err := checkAccountQuota(txApp, tenantID); err != nil {
+			return err
+		}
+
+		original := e.App
+		e.App = txApp
+		defer func() { e.App = original }()
+
+		return e.Next()
+	})
+}
+
+// checkAccountQuota 校验租户是否还能新增一个账号
+func checkAccountQuota(app core.App, tenantID string) error {
+	limit := 0
+	err := app.DB().Select("account_count").From("tenant").
+		Where(dbx.HashExp{"id": tenantID}).
+		Row(&limit)
+	if err != nil {
+		// 租户不存在时不在此处拦截
+		return nil
+	}
+
+	// -1（或未设置）表示不限制
+	if limit <= 0 {
+		return nil
+	}
+
+	if used := countTenantAccounts(app, tenantID); used >= limit {
+		return apis.NewBadRequestError(fmt.Sprintf("租户账号数量已达上限（%d/%d），请联系管理员扩容", used, limit), nil)
+	}
+	return nil
+}
+
+// countTenantAccounts 统计租户下未删除的用户数
+func countTenantAccounts(app core.App, tenantID string) int {
+	count := 0
+	_ = app.DB().Select("count(*)").From("users").
+		Where(dbx.HashExp{"tenant_id": tenantID}).
+		AndWhere(dbx.Not(dbx.HashExp{"del_flag": "1"})).
+		Row(&count)
+	return count
+}
@@ -0,0 +1,319 @@
+package tenant
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pocketbase-ruoyi/tools"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// 租户不可用时返回的错误码（位于错误响应的 data.tenant.code 中）
+const (
+	TenantErrNotFound = "tenant_not_found"
+	TenantErrDisabled = "tenant_disabled"
+	TenantErrExpired  = "tenant_expired"
+)
+
+// 租户状态值
+const (
+	tenantStatusNormal   = "0"
+	tenantStatusDisabled = "1"
+	tenantDelFlagDeleted = "1"
+)
+
+// tenantStatusCacheTTL 租户状态缓存时间；租户更新时立即失效
+const tenantStatusCacheTTL = time.Minute
+
+// registerTenantStatus 注册租户状态校验：登录与每个已认证请求都会校验所属租户，
+// 并注册每日到期检查任务（停用到期租户、发送到期提醒）。
+func registerTenantStatus(app *pocketbase.PocketBase) {
+	// 登录时校验用户所属租户
+	app.OnRecordAuthRequest("users").BindFunc(func(e *core.RecordAuthRequestEvent) error {
+		if e.Record != nil {
+			if err := CheckTenantAvailable(e.App, e.Record.GetString("tenant_id")); err != nil {
+				return err
+			}
+		}
+		return e.Next()
+	})
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		// 已认证请求校验用户所属租户（超级管理员账号不受限制）
+		se.Router.BindFunc(func(e *core.RequestEvent) error {
+			if e.Auth == nil || e.Auth.IsSuperuser() || e.Auth.Collection().Name != "users" {
+				return e.Next()
+			}
+			if err := CheckTenantAvailable(e.App, e.Auth.GetString("tenant_id")); err != nil {
+				return err
+			}
+			return e.Next()
+		})
+		return se.Next()
+	})
+
+	// 租户变更后清理状态缓存
+	app.OnRecordAfterUpdateSuccess("tenant").BindFunc(clearTenantStatusCache)
+	app.OnRecordAfterDeleteSuccess("tenant").BindFunc(clearTenantStatusCache)
+
+	cfg := loadTenantConfig()
+	err := app.Cron().Add("tenantExpireCheck", cfg.ExpireCheckCron, func() {
+		if err := disableExpiredTenants(app); err != nil {
+			app.Logger().Error("租户到期检查失败", "error", err)
+		}
+		if err := warnExpiringTenants(app, cfg.ExpireWarnDays); err != nil {
+			app.Logger().Error("租户到期提醒失败", "error", err)
+		}
+	})
+	if err != nil {
+		app.Logger().Error("租户到期检查任务注册失败", "cron", cfg.ExpireCheckCron, "error", err)
+	}
+}
+
+// tenantStatus 租户状态缓存项
+type tenantStatus struct {
+	Found      bool
+	Status     string
+	DelFlag    string
+	ExpireTime types.DateTime
+}
+
+func tenantStatusCacheKey(tenantID string) string {
+	return "tenant_status_" + tenantID
+}
+
+func clearTenantStatusCache(e *core.RecordEvent) error {
+	tools.CacheDelete(tenantStatusCacheKey(e.Record.Id))
+	return e.Next()
+}
+
+// CheckTenantAvailable 校验租户是否可用：存在、未删除、未停用且未过期。
+// 默认租户与未设置租户的用户不受限制。不可用时返回 403，data.tenant.code 为对应错误码。
+func CheckTenantAvailable(app core.App, tenantID string) error {
+	if tenantID == "" || tenantID == defaultTenantID {
+		return nil
+	}
+
+	st, ok := getTenantStatus(app, tenantID)
+	if !ok {
+		return nil
+	}
+
+	switch {
+	case !st.Found || st.DelFlag == tenantDelFlagDeleted:
+		return tenantUnavailableError(TenantErrNotFound, "租户不存在")
+	case st.Status == tenantStatusDisabled:
+		return tenantUnavailableError(TenantErrDisabled, "租户已停用，请联系管理员")
+	case isTenantExpired(st.ExpireTime, time.Now()):
+		return tenantUnavailableError(TenantErrExpired, "租户已过期，请联系管理员续期")
+	}
+	return nil
+}
+
+func tenantUnavailableError(code, msg string) *router.ApiError {
+	return apis.NewForbiddenError(msg, map[string]validation.Error{
+		"tenant": validation.NewError(code, msg),
+	})
+}
+
+// isTenantExpired 未设置到期时间视为永不过期
+func isTenantExpired(expire types.DateTime, now time.Time) bool {
+	return !expire.IsZero() && expire.Time().Before(now)
+}
+
+// getTenantStatus 读取租户状态（带缓存）；查询失败时返回 ok=false，不阻断请求
+func getTenantStatus(app core.App, tenantID string) (tenantStatus, bool) {
+	key := tenantStatusCacheKey(tenantID)
+	if v, ok := tools.CacheGetValue(key); ok {
+		if st, ok := v.(tenantStatus); ok {
+			return st, true
+		}
+	}
+
+	st := tenantStatus{}
+	rec, err := app.FindRecordById("tenant", tenantID)
+	if err == nil {
+		st.Found = true
+		st.Status = rec.GetString("status")
+		st.DelFlag = rec.GetString("del_flag")
+		st.ExpireTime = rec.GetDateTime("expire_time")
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return st, false
+	}
+
+	tools.CacheSetValue(key, st, tenantStatusCacheTTL)
+	return st, true
+}
+
+// disableExpiredTenants 停用已到期的租户，并使其所有用户的会话失效
+func disableExpiredTenants(app core.App) error {
+	now := types.NowDateTime()
+	tenants, err := app.FindRecordsByFilter(
+		"tenant",
+		"status = {:normal} && del_flag != {:deleted} && expire_time != '' && expire_time < {:now} && id != {:default}",
+		"", 0, 0,
+		dbx.Params{"normal": tenantStatusNormal, "deleted": tenantDelFlagDeleted, "now": now, "default": defaultTenantID},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tenants {
+		t.Set("status", tenantStatusDisabled)
+		if err := app.Save(t); err != nil {
+			app.Logger().Error("停用到期租户失败", "tenant", t.Id, "error", err)
+			continue
+		}
+
+		var userIDs []string
+		_ = app.DB().Select("id").From("users").Where(dbx.HashExp{"tenant_id": t.Id}).Column(&userIDs)
+		for _, userID := range userIDs {
+			if err := tools.RevokeUserSessions(app, userID); err != nil {
+				app.Logger().Error("清理租户用户会话失败", "tenant", t.Id, "user", userID, "error", err)
+			}
+		}
+		app.Logger().Info("租户已到期停用", "tenant", t.Id)
+	}
+	return nil
+}
+
+// warnExpiringTenants 向 days 天内到期租户的管理员发送到期提醒通知（同一到期时间只提醒一次）
+func warnExpiringTenants(app core.App, days int) error {
+	if days <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	from, _ := types.ParseDateTime(now)
+	to, _ := types.ParseDateTime(now.AddDate(0, 0, days))
+	tenants, err := app.FindRecordsByFilter(
+		"tenant",
+		"status = {:normal} && del_flag != {:deleted} && expire_time >= {:from} && expire_time <= {:to} && id != {:default}",
+		"", 0, 0,
+		dbx.Params{"normal": tenantStatusNormal, "deleted": tenantDelFlagDeleted, "from": from, "to": to, "default": defaultTenantID},
+	)
+	if err != nil {
+		return err
+	}
+
+	coll, err := app.FindCollectionByNameOrId("notice")
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tenants {
+		expire := t.GetDateTime("expire_time").Time().Local().Format("2006-01-02 15:04")
+		title := "租户到期提醒：" + expire
+		if existing, _ := app.FindFirstRecordByFilter("notice", "tenant_id = {:tid} && notice_title = {:title}",
+			dbx.Params{"tid": t.Id, "title": title}); existing != nil {
+			continue
+		}
+
+		// 发送给租户管理员；找不到管理员时退化为租户内公告，避免提醒丢失
+		adminID := findTenantAdminUser(app, t.Id, "")
+		if adminID == "" {
+			app.Logger().Warn("租户没有管理员用户，到期提醒改为租户公告", "tenant", t.Id)
+		}
+
+		nr := core.NewRecord(coll)
+		nr.Set("tenant_id", t.Id)
+		nr.Set("receiver_id", adminID)
+		nr.Set("notice_title", title)
+		nr.Set("notice_type", "1") // 通知
+		nr.Set("notice_content", fmt.Sprintf(
+			"<p>%s 管理员您好：贵租户（%s）将于 %s 到期，到期后所有用户将无法登录，请及时联系平台续期。</p>",
+			t.GetString("contact_user_name"), t.GetString("company_name"), expire,
+		))
+		nr.Set("status", "0")
+		nr.Set("remark", "系统自动发送")
+		if err := app.Save(nr); err != nil {
+			app.Logger().Error("发送租户到期提醒失败", "tenant", t.Id, "error", err)
+		}
+	}
+	return nil
+}
+
+// tenantConfig 对应 config/tenant.yml
+type tenantConfig struct {
+	ExpireCheckCron    string
+	ExpireWarnDays     int
+	PurgeRetentionDays int
+	PurgeCron          string
+	UsageFlushCron     string
+	UsageRollupCron    string
+}
+
+var (
+	tenantConfigOnce sync.Once
+	tenantConfigVal  tenantConfig
+)
+
+// loadTenantConfig 读取 config/tenant.yml（简单的 key: value 解析），缺失时使用默认值
+func loadTenantConfig() tenantConfig {
+	tenantConfigOnce.Do(func() {
+		tenantConfigVal = tenantConfig{
+			ExpireCheckCron:    "0 1 * * *",
+			ExpireWarnDays:     7,
+			PurgeRetentionDays: 30,
+			PurgeCron:          "0 3 * * *",
+			UsageFlushCron:     "*/5 * * * *",
+			UsageRollupCron:    "0 * * * *",
+		}
+
+		data, err := os.ReadFile(filepath.Join("config", "tenant.yml"))
+		if err != nil {
+			return
+		}
+		for _, ln := range strings.Split(string(data), "\n") {
+			if i := strings.Index(ln, "#"); i >= 0 {
+				ln = ln[:i]
+			}
+			k, v, ok := strings.Cut(strings.TrimSpace(ln), ":")
+			if !ok {
+				continue
+			}
+			v = strings.Trim(strings.TrimSpace(v), "\"'")
+			switch strings.TrimSpace(k) {
+			case "expireCheckCron":
+				if v != "" {
+					tenantConfigVal.ExpireCheckCron = v
+				}
+			case "expireWarnDays":
+				if n, err := strconv.Atoi(v); err == nil {
+					tenantConfigVal.ExpireWarnDays = n
+				}
+			case "purgeRetentionDays":
+				if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+					tenantConfigVal.PurgeRetentionDays = n
+				}
+			case "purgeCron":
+				if v != "" {
+					tenantConfigVal.PurgeCron = v
+				}
+			case "usageFlushCron":
+				if v != "" {
+					tenantConfigVal.UsageFlushCron = v
+				}
+			case "usageRollupCron":
+				if v != "" {
+					tenantConfigVal.UsageRollupCron = v
+				}
+			}
+		}
+	})
+	return tenantConfigVal
+}
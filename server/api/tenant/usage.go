@@ -0,0 +1,252 @@
+package tenant
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"pocketbase-ruoyi/api/auth"
+	"pocketbase-ruoyi/tools"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// tenantUsageCollection 租户月度用量汇总集合
+const tenantUsageCollection = "tenant_usage"
+
+// tenantUsage 租户用量快照
+type tenantUsage struct {
+	TenantID     string         `json:"tenant_id"`
+	Month        string         `json:"month"`
+	APICalls     int64          `json:"api_calls"`
+	StorageBytes int64          `json:"storage_bytes"`
+	UserCount    int            `json:"user_count"`
+	RecordCounts map[string]int `json:"record_counts"`
+}
+
+// tenantUsageLimits 租户套餐的用量限制，0 表示不限制
+type tenantUsageLimits struct {
+	APICallLimit   int64 `json:"api_call_limit"`
+	StorageLimitMB int64 `json:"storage_limit_mb"`
+	AccountCount   int   `json:"account_count"`
+}
+
+// registerTenantUsage 注册租户用量计量：
+//   - API 调用次数由 RBAC 中间件累加（超出套餐 api_call_limit 返回 429），定期写入 tenant_usage；
+//   - 上传文件时校验套餐存储上限 storage_limit_mb，超出返回 507；
+//   - 定时汇总存储量、用户数与各集合记录数到当月的 tenant_usage；
+//   - GET /api/system/tenant/usage/{tenantId} 返回当前用量、限制与月度历史。
+func registerTenantUsage(app *pocketbase.PocketBase) {
+	app.OnRecordCreateRequest("oss").BindFunc(checkStorageLimit)
+	app.OnRecordUpdateRequest("oss").BindFunc(checkStorageLimit)
+
+	// 套餐或租户变更后立即生效新的用量限制
+	clearLimits := func(e *core.RecordEvent) error {
+		tools.ClearTenantPackageLimits()
+		return e.Next()
+	}
+	app.OnRecordAfterUpdateSuccess("tenant_package").BindFunc(clearLimits)
+	app.OnRecordAfterDeleteSuccess("tenant_package").BindFunc(clearLimits)
+	app.OnRecordAfterUpdateSuccess("tenant").BindFunc(clearLimits)
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/system/tenant/usage/{tenantId}", func(e *core.RequestEvent) error {
+			tenantID := e.Request.PathValue("tenantId")
+			if !auth.IsSuperuser(e) && tenantID != tools.GetUserTenant(e) {
+				return e.ForbiddenError("只能查看本租户的用量", nil)
+			}
+
+			tenant, err := e.App.FindRecordById("tenant", tenantID)
+			if err != nil {
+				return e.NotFoundError("租户不存在", err)
+			}
+
+			current, err := collectTenantUsage(e.App, tenant.Id)
+			if err != nil {
+				return e.InternalServerError("统计租户用量失败", err)
+			}
+
+			history, err := e.App.FindRecordsByFilter(tenantUsageCollection, "tenant_id={:tid}", "-month", 12, 0, dbx.Params{"tid": tenant.Id})
+			if err != nil {
+				return e.InternalServerError("获取租户用量历史失败", err)
+			}
+
+			return tools.JSONSuccess(e, map[string]any{
+				"current": current,
+				"limits": tenantUsageLimits{
+					APICallLimit:   tools.TenantPackageLimit(e.App, tenant.Id, "api_call_limit"),
+					StorageLimitMB: tools.TenantPackageLimit(e.App, tenant.Id, "storage_limit_mb"),
+					AccountCount:   tenant.GetInt("account_count"),
+				},
+				"history": history,
+			})
+		}).BindFunc(auth.RBAC("system:tenant:query"))
+
+		return se.Next()
+	})
+
+	cfg := loadTenantConfig()
+	if err := app.Cron().Add("tenantUsageFlush", cfg.UsageFlushCron, func() {
+		flushTenantAPICalls(app)
+	}); err != nil {
+		app.Logger().Error("租户用量写入任务注册失败", "cron", cfg.UsageFlushCron, "error", err)
+	}
+	if err := app.Cron().Add("tenantUsageRollup", cfg.UsageRollupCron, func() {
+		rollupTenantUsage(app)
+	}); err != nil {
+		app.Logger().Error("租户用量汇总任务注册失败", "cron", cfg.UsageRollupCron, "error", err)
+	}
+
+	// 停止服务前写入内存中的调用次数
+	app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		flushTenantAPICalls(e.App)
+		return e.Next()
+	})
+}
+
+// checkStorageLimit 上传文件前校验租户套餐的存储上限（更新时不计入被替换的旧文件）
+func checkStorageLimit(e *core.RecordRequestEvent) error {
+	files := e.Record.GetUnsavedFiles("file")
+	if len(files) == 0 || files[0] == nil {
+		return e.Next()
+	}
+
+	tenantID := e.Record.GetString("tenant_id")
+	if tenantID == "" {
+		tenantID = tools.GetUserTenant(e.RequestEvent)
+	}
+
+	limitMB := tools.TenantPackageLimit(e.App, tenantID, "storage_limit_mb")
+	if limitMB <= 0 {
+		return e.Next()
+	}
+
+	used, err := tenantStorageBytes(e.App, tenantID, e.Record.Id)
+	if err != nil {
+		return e.InternalServerError("统计租户存储用量失败", err)
+	}
+	if used+files[0].Size > limitMB*1024*1024 {
+		return router.NewApiError(http.StatusInsufficientStorage,
+			fmt.Sprintf("租户存储空间不足（上限 %d MB），请联系管理员升级套餐", limitMB), nil)
+	}
+
+	return e.Next()
+}
+
+// tenantStorageBytes 统计租户 oss 文件总大小，excludeID 不为空时排除该记录
+func tenantStorageBytes(app core.App, tenantID, excludeID string) (int64, error) {
+	var total int64
+	q := app.DB().Select("COALESCE(SUM(file_size), 0)").From("oss").
+		Where(dbx.HashExp{"tenant_id": tenantID})
+	if excludeID != "" {
+		q.AndWhere(dbx.Not(dbx.HashExp{"id": excludeID}))
+	}
+	err := q.Row(&total)
+	return total, err
+}
+
+// collectTenantUsage 统计租户当前用量
+func collectTenantUsage(app core.App, tenantID string) (tenantUsage, error) {
+	usage := tenantUsage{
+		TenantID:     tenantID,
+		Month:        tools.UsageMonth(time.Now()),
+		APICalls:     tools.TenantAPICalls(app, tenantID),
+		UserCount:    countTenantAccounts(app, tenantID),
+		RecordCounts: map[string]int{},
+	}
+
+	var err error
+	if usage.StorageBytes, err = tenantStorageBytes(app, tenantID, ""); err != nil {
+		return usage, err
+	}
+
+	scoped, _, err := tenantPurgePlan(app)
+	if err != nil {
+		return usage, err
+	}
+	for _, c := range scoped {
+		if c.Name == tenantUsageCollection {
+			continue
+		}
+		count := 0
+		err := app.DB().Select("count(*)").From(c.Name).
+			Where(tools.GetDataScopeFields(c).Tenant.ExpressionIn(tenantID)).
+			Row(&count)
+		if err != nil {
+			return usage, err
+		}
+		usage.RecordCounts[c.Name] = count
+	}
+
+	return usage, nil
+}
+
+// findOrNewTenantUsage 查找租户某月的用量记录，不存在时新建（未保存）
+func findOrNewTenantUsage(app core.App, tenantID, month string) (*core.Record, error) {
+	rec, err := app.FindFirstRecordByFilter(tenantUsageCollection, "tenant_id={:tid} && month={:month}",
+		dbx.Params{"tid": tenantID, "month": month})
+	if err == nil {
+		return rec, nil
+	}
+
+	coll, err := app.FindCachedCollectionByNameOrId(tenantUsageCollection)
+	if err != nil {
+		return nil, err
+	}
+	rec = core.NewRecord(coll)
+	rec.Set("tenant_id", tenantID)
+	rec.Set("month", month)
+	return rec, nil
+}
+
+// flushTenantAPICalls 将内存中累加的 API 调用次数写入 tenant_usage，失败时放回待下次写入
+func flushTenantAPICalls(app core.App) {
+	for _, p := range tools.TakePendingAPICalls() {
+		err := app.RunInTransaction(func(txApp core.App) error {
+			rec, err := findOrNewTenantUsage(txApp, p.TenantID, p.Month)
+			if err != nil {
+				return err
+			}
+			rec.Set("api_calls", rec.GetInt("api_calls")+int(p.Calls))
+			return txApp.Save(rec)
+		})
+		if err != nil {
+			tools.RestorePendingAPICalls(p)
+			app.Logger().Error("写入租户接口调用次数失败", "tenant", p.TenantID, "month", p.Month, "error", err)
+		}
+	}
+}
+
+// rollupTenantUsage 汇总所有未删除租户的存储量、用户数与记录数到当月用量记录
+func rollupTenantUsage(app core.App) {
+	flushTenantAPICalls(app)
+
+	tenantIDs := []string{}
+	err := app.DB().Select("id").From("tenant").
+		Where(dbx.Not(dbx.HashExp{"del_flag": tenantDelFlagDeleted})).
+		Column(&tenantIDs)
+	if err != nil {
+		app.Logger().Error("租户用量汇总失败", "error", err)
+		return
+	}
+
+	month := tools.UsageMonth(time.Now())
+	for _, tenantID := range tenantIDs {
+		usage, err := collectTenantUsage(app, tenantID)
+		if err == nil {
+			var rec *core.Record
+			if rec, err = findOrNewTenantUsage(app, tenantID, month); err == nil {
+				rec.Set("storage_bytes", usage.StorageBytes)
+				rec.Set("user_count", usage.UserCount)
+				rec.Set("record_counts", usage.RecordCounts)
+				err = app.Save(rec)
+			}
+		}
+		if err != nil {
+			app.Logger().Error("租户用量汇总失败", "tenant", tenantID, "error", err)
+		}
+	}
+}
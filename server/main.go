@@ -39,6 +39,7 @@ func main() {
 	auth.RegisterAuth(app)
 	monitor.RegisterMonitorLogininfor(app)
 	monitor.RegisterMonitorOnline(app)
+	monitor.RegisterMonitorRateLimit(app)
 	menu.RegisterSystemMenu(app)
 	system.RegisterSystemDept(app)
 	system.RegisterSystemUserProfile(app)
@@ -52,6 +53,7 @@ func main() {
 	tenant.RegisterTenant(app)
 
 	auth.RegisterDataScope(app)
+	auth.RegisterDataScopePreview(app)
 
 	custom.RegisterCustom(app)
 
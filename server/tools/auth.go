@@ -29,3 +29,24 @@ func GetAuthTokenFromRequest(e *core.RequestEvent) string {
 	}
 	return token
 }
+
+// RevokeUserSessions 使用户的所有登录会话失效（重置 tokenKey、清理登录来源与租户切换会话）
+func RevokeUserSessions(app core.App, userID string) error {
+	record, err := app.FindRecordById("users", userID)
+	if err != nil {
+		return err
+	}
+	record.RefreshTokenKey()
+	if err := app.Save(record); err != nil {
+		return err
+	}
+
+	_, err = app.DB().Delete("_authOrigins", dbx.HashExp{
+		"collectionRef": record.Collection().Id,
+		"recordRef":     record.Id,
+	}).Execute()
+
+	revokeUserTenantSwitches(app, record.Id)
+
+	return err
+}
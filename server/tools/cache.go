@@ -6,10 +6,10 @@ import (
 )
 
 // item 表示缓存条目，包含值与过期时间。
-// 这里使用 string 作为通用值类型，已满足当前 uuid->answer 的需求；
-// 如需扩展可改为 any。
+// 值类型为 any：字符串场景（如 uuid->answer）使用 CacheSet/CacheGet，
+// 其它结构化值（如数据权限表达式）使用 CacheSetValue/CacheGetValue。
 type item struct {
-	value    string
+	value    any
 	expireAt time.Time
 }
 
@@ -32,22 +32,37 @@ func init() {
 
 // CacheSet 设置键值并指定 TTL。
 func CacheSet(key, value string, ttl time.Duration) {
+	CacheSetValue(key, value, ttl)
+}
+
+// CacheGet 获取键值；若不存在或已过期则返回 ok=false，并清理该键。
+func CacheGet(key string) (val string, ok bool) {
+	v, ok := CacheGetValue(key)
+	if !ok {
+		return "", false
+	}
+	val, ok = v.(string)
+	return val, ok
+}
+
+// CacheSetValue 设置任意类型的值并指定 TTL。
+func CacheSetValue(key string, value any, ttl time.Duration) {
 	cacheMu.Lock()
 	cache[key] = item{value: value, expireAt: time.Now().Add(ttl)}
 	cacheMu.Unlock()
 }
 
-// CacheGet 获取键值；若不存在或已过期则返回 ok=false，并清理该键。
-func CacheGet(key string) (val string, ok bool) {
+// CacheGetValue 获取任意类型的值；若不存在或已过期则返回 ok=false，并清理该键。
+func CacheGetValue(key string) (val any, ok bool) {
 	cacheMu.RLock()
 	it, exists := cache[key]
 	cacheMu.RUnlock()
 	if !exists {
-		return "", false
+		return nil, false
 	}
 	if time.Now().After(it.expireAt) {
 		CacheDelete(key)
-		return "", false
+		return nil, false
 	}
 	return it.value, true
 }
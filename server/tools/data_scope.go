@@ -1,8 +1,8 @@
 package tools
 
 import (
+	"errors"
 	"fmt"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -53,18 +53,6 @@ func loadDataScopeWhitelist() map[string]struct{} {
 	return collMap
 }
 
-// getFieldName safely extracts the field name from a possibly nil object (assuming it implements GetName() string)
-func getFieldName(f interface{}) string {
-	if f == nil {
-		return ""
-	}
-	type namer interface{ GetName() string }
-	if n, ok := f.(namer); ok {
-		return n.GetName()
-	}
-	return ""
-}
-
 // isSuperuserRequest checks whether the current request's user is a super admin.
 // It mirrors api/auth.IsSuperuser but is self-contained to avoid package cycles.
 func isSuperuserRequest(e *core.RequestEvent) bool {
@@ -99,121 +87,146 @@ func IsAdmin(e *core.RequestEvent) bool {
 	return count > 0
 }
 
-// getDeptIDsIncludingChildren returns the user's department id plus all children department ids
-func getDeptIDsIncludingChildren(e *core.RequestEvent, userDeptID string) []string {
-	ids := []string{}
-	if userDeptID == "" {
-		return ids
-	}
-	ids = append(ids, userDeptID)
+// deptSubtreeQuery returns a SELECT yielding deptID and all of its descendants.
+// The subtree is walked in SQL with a recursive CTE over dept.parent_id, so the
+// department ids are never expanded in Go or inlined into the filter.
+// Soft-deleted departments (del_flag=1) and everything below them are skipped.
+func deptSubtreeQuery(deptID string) (string, dbx.Params) {
+	p := dataScopeParamName()
+	return fmt.Sprintf(
+		"WITH RECURSIVE ds_subtree(id) AS ("+
+			"SELECT {:%s} UNION "+
+			"SELECT d.id FROM dept d INNER JOIN ds_subtree s ON d.parent_id = s.id WHERE d.del_flag != '1'"+
+			") SELECT id FROM ds_subtree",
+		p,
+	), dbx.Params{p: deptID}
+}
 
-	// query children by ancestors LIKE pattern, following api/system.GetChildrensByDeptID logic
-	type row struct {
-		ID string `db:"id"`
-	}
-	var rows []row
-	_ = e.App.DB().Select("id").From("dept").
-		Where(
-			dbx.Or(
-				dbx.Like("ancestors", fmt.Sprintf(",%s,", userDeptID)),
-				dbx.Like("ancestors", fmt.Sprintf(",%s", userDeptID)).Match(true, false),
-			),
-		).
-		All(&rows)
-	for _, r := range rows {
-		if r.ID != "" {
-			ids = append(ids, r.ID)
-		}
-	}
-	return ids
+// roleDeptQuery returns a SELECT yielding the (not soft-deleted) dept ids bound to a role in role_dept.
+func roleDeptQuery(roleID string) (string, dbx.Params) {
+	p := dataScopeParamName()
+	return fmt.Sprintf(
+		"SELECT rd.dept FROM role_dept rd INNER JOIN dept d ON d.id = rd.dept "+
+			"WHERE rd.role = {:%s} AND d.del_flag != '1'",
+		p,
+	), dbx.Params{p: roleID}
 }
 
-// roleDeptIDs returns dept ids bound to a role from role_dept table
-func roleDeptIDs(app core.App, roleID string) []string {
-	if roleID == "" {
-		return nil
-	}
-	depts, _ := app.FindRecordsByFilter("role_dept", "role={:roleID}", "", 999, 0, dbx.Params{"roleID": roleID})
-	if len(depts) == 0 {
-		return nil
-	}
-	ids := make([]string, 0, len(depts))
-	for _, d := range depts {
-		if id := d.GetString("dept"); id != "" {
-			ids = append(ids, id)
+// ledUsersQuery returns a SELECT yielding the users of the departments led by userID
+// (dept.leader) and of their sub-departments, skipping soft-deleted departments.
+func ledUsersQuery(userID string) (string, dbx.Params) {
+	p := dataScopeParamName()
+	return fmt.Sprintf(
+		"WITH RECURSIVE ds_led(id) AS ("+
+			"SELECT id FROM dept WHERE leader = {:%s} AND del_flag != '1' UNION "+
+			"SELECT d.id FROM dept d INNER JOIN ds_led s ON d.parent_id = s.id WHERE d.del_flag != '1'"+
+			") SELECT u.id FROM users u WHERE u.dept_id IN (SELECT id FROM ds_led)",
+		p,
+	), dbx.Params{p: userID}
+}
+
+// postPeersQuery returns a SELECT yielding userID and the users sharing any of its posts (user_post).
+func postPeersQuery(userID string) (string, dbx.Params) {
+	p := dataScopeParamName()
+	return fmt.Sprintf(
+		"SELECT {:%s} UNION "+
+			"SELECT peer.user FROM user_post mine INNER JOIN user_post peer ON peer.post = mine.post "+
+			"WHERE mine.user = {:%s}",
+		p, p,
+	), dbx.Params{p: userID}
+}
+
+// DataScopeRole is the role input of FilterBuilderForRole.
+// DeptIDs, when non-nil, replaces the role's role_dept rows for custom scopes
+// (used to preview role settings that are not saved yet).
+type DataScopeRole struct {
+	ID        string
+	DataScope string
+	DeptIDs   []string
+}
+
+// noneMatch is used when a scope grants no rows at all (e.g. custom scope without departments).
+var noneMatch = dbx.NewExp("1=0")
+
+// customDeptExpression returns the custom (role_dept) department restriction of a role.
+func customDeptExpression(role DataScopeRole, field DataScopeField) dbx.Expression {
+	if role.DeptIDs != nil {
+		if len(role.DeptIDs) == 0 {
+			return noneMatch
 		}
+		return field.ExpressionIn(role.DeptIDs...)
 	}
-	return ids
+	if role.ID == "" {
+		return noneMatch
+	}
+	return field.ExpressionInQuery(roleDeptQuery(role.ID))
 }
 
-// FilterBuilderForRole returns a dbx expression representing data scope for a single role,
-// and whether to stop processing (when dataScope=="1").
+// FilterBuilderForRole returns a dbx expression representing data scope for a single role
+// of the given user, and whether to stop processing (when dataScope=="1").
+// fields carries the collection's dept/owner columns (see GetDataScopeFields).
+//
+// Codes: 1 all, 2 custom departments, 3 own department, 4 own department and below,
+// 5 own records, 6 custom departments or own department and below,
+// 7 own records and those of users in departments led by the user (dept.leader, incl. sub-departments),
+// 8 own records and those of users sharing one of the user's posts (user_post).
+//
+// Department based scopes are expressed as SQL subqueries, keeping the expression size
+// independent of the number of departments.
 func FilterBuilderForRole(
-	e *core.RequestEvent,
 	app core.App,
-	roleID, dataScope, createDeptFieldName, createByFieldName, userDeptID string,
+	role DataScopeRole,
+	fields DataScopeFields,
+	userID, userDeptID string,
 ) (dbx.Expression, bool) {
-	switch dataScope {
+	switch role.DataScope {
 	case "1":
 		return nil, true
 	case "2":
-		if createDeptFieldName == "" {
-			return nil, false
-		}
-		ids := roleDeptIDs(app, roleID)
-		if len(ids) > 0 {
-			vals := make([]interface{}, 0, len(ids))
-			for _, id := range ids {
-				vals = append(vals, id)
-			}
-			return dbx.In(createDeptFieldName, vals...), false
+		if !fields.Dept.IsZero() {
+			return customDeptExpression(role, fields.Dept), false
 		}
 	case "3":
-		if createDeptFieldName != "" && userDeptID != "" {
-			return dbx.HashExp{createDeptFieldName: userDeptID}, false
+		if !fields.Dept.IsZero() && userDeptID != "" {
+			return fields.Dept.ExpressionIn(userDeptID), false
 		}
 	case "4":
-		if createDeptFieldName == "" {
-			return nil, false
-		}
-		ids := getDeptIDsIncludingChildren(e, userDeptID)
-		if len(ids) > 0 {
-			vals := make([]interface{}, 0, len(ids))
-			for _, id := range ids {
-				vals = append(vals, id)
-			}
-			return dbx.In(createDeptFieldName, vals...), false
+		if !fields.Dept.IsZero() && userDeptID != "" {
+			return fields.Dept.ExpressionInQuery(deptSubtreeQuery(userDeptID)), false
 		}
 	case "5":
-		if createByFieldName != "" {
-			return dbx.HashExp{createByFieldName: e.Auth.Id}, false
+		if !fields.Owner.IsZero() && userID != "" {
+			return fields.Owner.ExpressionIn(userID), false
 		}
 	case "6":
-		if createDeptFieldName == "" {
+		if fields.Dept.IsZero() {
 			return nil, false
 		}
-		set := make(map[string]struct{})
-		for _, id := range roleDeptIDs(app, roleID) {
-			if id != "" {
-				set[id] = struct{}{}
-			}
+		exps := []dbx.Expression{customDeptExpression(role, fields.Dept)}
+		if userDeptID != "" {
+			exps = append(exps, fields.Dept.ExpressionInQuery(deptSubtreeQuery(userDeptID)))
 		}
-		for _, id := range getDeptIDsIncludingChildren(e, userDeptID) {
-			if id != "" {
-				set[id] = struct{}{}
-			}
+		return dbx.Or(exps...), false
+	case "7":
+		if !fields.Owner.IsZero() && userID != "" {
+			return dbx.Or(
+				fields.Owner.ExpressionIn(userID),
+				fields.Owner.ExpressionInQuery(ledUsersQuery(userID)),
+			), false
 		}
-		if len(set) > 0 {
-			vals := make([]interface{}, 0, len(set))
-			for id := range set {
-				vals = append(vals, id)
-			}
-			return dbx.In(createDeptFieldName, vals...), false
+	case "8":
+		if !fields.Owner.IsZero() && userID != "" {
+			return fields.Owner.ExpressionInQuery(postPeersQuery(userID)), false
 		}
 	}
 	return nil, false
 }
 
+// dataScopeNeedsUserDept reports whether a data scope code is relative to the user's department.
+func dataScopeNeedsUserDept(scope string) bool {
+	return scope == "3" || scope == "4"
+}
+
 // getAllRolesByUser returns minimal role info list for a user.
 type roleLite struct {
 	ID        string `db:"id"`
@@ -232,51 +245,96 @@ func getAllRolesByUser(e *core.RequestEvent, userID string) []roleLite {
 
 var oneByOne = dbx.NewExp("1=1")
 
-// BuildDataScopeExpression builds a dbx expression equivalent to the router-level string filter.
-// - Applies tenant_id restriction if such field exists.
-// - If collection is not whitelisted, merges data-scope constraints across all user roles (OR).
-// - Returns nil for superuser or unauthenticated requests.
-// When tenant/department info is required but missing, returns an error.
-func BuildDataScopeExpression(e *core.RequestEvent, collectionName string) dbx.Expression {
-	if e == nil || e.App == nil || e.Auth == nil {
-		return oneByOne
+var (
+	// ErrDataScopeTenantMissing is returned when a tenant scoped collection is accessed without tenant info.
+	ErrDataScopeTenantMissing = errors.New("User tenant information is missing; cannot access tenant data")
+	// ErrDataScopeDeptMissing is returned when a department scope applies but the user has no department.
+	ErrDataScopeDeptMissing = errors.New("User department information is missing; cannot access department data")
+)
+
+// DataScopeExpression builds the tenant + role data scope of collection for the current user.
+//   - Returns nil (no restriction) for superusers and unscoped collections.
+//   - Unauthenticated requests are restricted to the tenant resolved from the request domain, if any.
+//   - Admins and app superusers are restricted to their tenant only.
+//   - Other users get the OR of their roles' data scopes, unless the collection is whitelisted.
+//
+// When qualified is true, columns are prefixed with the collection table name so the
+// expression can be used in queries joining other tables.
+// Results are cached per user, tenant and collection until InvalidateDataScopeCache is called.
+func DataScopeExpression(e *core.RequestEvent, collection *core.Collection, qualified bool) (dbx.Expression, error) {
+	if e == nil || e.App == nil || collection == nil {
+		return nil, nil
+	}
+	if e.Auth == nil {
+		return domainTenantExpression(e, collection, qualified), nil
+	}
+	if e.Auth.IsSuperuser() {
+		return nil, nil
 	}
 
-	collection, err := e.App.FindCachedCollectionByNameOrId(collectionName)
-	if err != nil || collection == nil {
-		return oneByOne
+	userTenantID := GetUserTenant(e)
+	cacheKey := dataScopeCacheKey(e.Auth.Id, userTenantID, collection.Name, qualified)
+	if v, ok := CacheGetValue(cacheKey); ok {
+		if cached, ok := v.(dataScopeCacheEntry); ok {
+			return cached.exp, cached.err
+		}
+	}
+
+	exp, err := buildDataScopeExpression(e, collection, userTenantID, qualified)
+	CacheSetValue(cacheKey, dataScopeCacheEntry{exp: exp, err: err}, dataScopeCacheTTL)
+
+	return exp, err
+}
+
+// domainTenantExpression restricts anonymous requests to the tenant bound to the request domain.
+func domainTenantExpression(e *core.RequestEvent, collection *core.Collection, qualified bool) dbx.Expression {
+	tenantID := GetDomainTenant(e)
+	if tenantID == "" {
+		return nil
+	}
+	fields := GetDataScopeFields(collection)
+	if qualified {
+		fields = fields.WithTable(collection.Name)
+	}
+	return fields.Tenant.ExpressionIn(tenantID)
+}
+
+func buildDataScopeExpression(e *core.RequestEvent, collection *core.Collection, userTenantID string, qualified bool) (dbx.Expression, error) {
+	fields := GetDataScopeFields(collection)
+	if qualified {
+		fields = fields.WithTable(collection.Name)
 	}
 
 	// tenant filter if field exists
 	var tenantExp dbx.Expression
-	if f := collection.Fields.GetByName("tenant_id"); f != nil {
-		userTenantID := GetUserTenant(e)
+	if !fields.Tenant.IsZero() {
 		if userTenantID == "" {
-			return oneByOne
+			return nil, ErrDataScopeTenantMissing
 		}
-		tenantExp = dbx.HashExp{getFieldName(f): userTenantID}
+		tenantExp = fields.Tenant.ExpressionIn(userTenantID)
 	}
 
 	if IsAdmin(e) || isSuperuserRequest(e) {
-		return tenantExp
+		return tenantExp, nil
 	}
 
 	// data scope (skip if whitelisted)
 	var dataExp dbx.Expression
 	collWhitelist := loadDataScopeWhitelist()
 	if _, ok := collWhitelist[collection.Name]; !ok {
-		createDeptField := collection.Fields.GetByName("create_dept")
-		createByField := collection.Fields.GetByName("create_by")
 		userDeptID := e.Auth.GetString("dept_id")
 
 		roles := getAllRolesByUser(e, e.Auth.Id)
 		var roleExps []dbx.Expression
 		for _, role := range roles {
+			if userDeptID == "" && !fields.Dept.IsZero() && dataScopeNeedsUserDept(role.DataScope) {
+				return nil, ErrDataScopeDeptMissing
+			}
 			exp, stop := FilterBuilderForRole(
-				e, e.App,
-				role.ID, role.DataScope,
-				getFieldName(createDeptField), getFieldName(createByField),
-				userDeptID,
+				e.App,
+				DataScopeRole{ID: role.ID, DataScope: role.DataScope},
+				fields,
+				e.Auth.Id, userDeptID,
 			)
 			if stop {
 				roleExps = nil
@@ -287,104 +345,38 @@ func BuildDataScopeExpression(e *core.RequestEvent, collectionName string) dbx.E
 			}
 		}
 		if len(roleExps) > 0 {
-			if userDeptID == "" {
-				return oneByOne
-			}
 			dataExp = dbx.Or(roleExps...)
 		}
 	}
 
 	switch {
 	case tenantExp != nil && dataExp != nil:
-		return dbx.And(tenantExp, dataExp)
+		return dbx.And(tenantExp, dataExp), nil
 	case tenantExp != nil:
-		return tenantExp
-	case dataExp != nil:
-		return dataExp
+		return tenantExp, nil
 	default:
-		return oneByOne
+		return dataExp, nil
 	}
 }
 
-// AppendDataScopeToURLQuery is a helper mirroring the router-level behavior for building ?filter= query parts.
-// It is optional; kept here in case external callers need to mutate Request URL directly like RegisterDataScope does.
-func AppendDataScopeToURLQuery(e *core.RequestEvent, collectionName string) error {
-	if e == nil || e.App == nil || e.Auth == nil || isSuperuserRequest(e) {
-		return nil
+// BuildDataScopeExpression builds a dbx expression equivalent to the router-level data scope.
+// - Applies the tenant restriction if the collection has a tenant field (tenant_id or its mapping).
+// - If collection is not whitelisted, merges data-scope constraints across all user roles (OR).
+// - Returns 1=1 for superuser or unauthenticated requests, and when tenant/department
+// info is required but missing (use DataScopeExpression to get the error instead).
+func BuildDataScopeExpression(e *core.RequestEvent, collectionName string) dbx.Expression {
+	if e == nil || e.App == nil || e.Auth == nil {
+		return oneByOne
 	}
+
 	collection, err := e.App.FindCachedCollectionByNameOrId(collectionName)
 	if err != nil || collection == nil {
-		return nil
-	}
-
-	query := e.Request.URL.Query()
-	// tenant
-	if f := collection.Fields.GetByName("tenant_id"); f != nil {
-		userTenantID := GetUserTenant(e)
-		if userTenantID == "" {
-			return fmt.Errorf("User tenant information is missing; cannot access tenant data")
-		}
-		oldFilter := query.Get("filter")
-		tenantFilter := fmt.Sprintf("%s=\"%s\"", getFieldName(f), userTenantID)
-		if oldFilter == "" {
-			e.Request.URL.RawQuery = url.Values{"filter": {tenantFilter}}.Encode()
-		} else {
-			e.Request.URL.RawQuery = url.Values{"filter": {fmt.Sprintf("(%s) && %s", oldFilter, tenantFilter)}}.Encode()
-		}
-	}
-
-	// data scope
-	collWhitelist := loadDataScopeWhitelist()
-	if _, ok := collWhitelist[collection.Name]; ok {
-		return nil
+		return oneByOne
 	}
 
-	createDeptField := collection.Fields.GetByName("create_dept")
-	createByField := collection.Fields.GetByName("create_by")
-	userDeptID := e.Auth.GetString("dept_id")
-
-	roles := getAllRolesByUser(e, e.Auth.Id)
-	var parts []string
-	for _, role := range roles {
-		exp, stop := FilterBuilderForRole(
-			e, e.App,
-			role.ID, role.DataScope,
-			getFieldName(createDeptField), getFieldName(createByField),
-			userDeptID,
-		)
-		if stop {
-			parts = nil
-			break
-		}
-		if exp != nil {
-			// convert expression back to simple equalities joined by OR when possible
-			// For simplicity, we only support the equalities that FilterBuilderForRole produces
-			// and fall back to no-op if complex expressions are present.
-			// Callers should prefer BuildDataScopeExpression for DB querying.
-			// Here we attempt to reconstruct patterns field="id" joined by ||.
-			switch v := exp.(type) {
-			case dbx.HashExp:
-				for k, val := range v {
-					if s, ok := val.(string); ok {
-						parts = append(parts, fmt.Sprintf("%s=\"%s\"", k, s))
-					}
-				}
-			default:
-				// best-effort not implemented for non-HashExp; skip adding
-			}
-		}
-	}
-	if len(parts) > 0 {
-		if userDeptID == "" {
-			return fmt.Errorf("User department information is missing; cannot access department data")
-		}
-		joined := "(" + strings.Join(parts, ") || (") + ")"
-		oldFilter := query.Get("filter")
-		if oldFilter == "" {
-			e.Request.URL.RawQuery = url.Values{"filter": {joined}}.Encode()
-		} else {
-			e.Request.URL.RawQuery = url.Values{"filter": {fmt.Sprintf("(%s) && %s", oldFilter, joined)}}.Encode()
-		}
+	exp, err := DataScopeExpression(e, collection, false)
+	if err != nil || exp == nil {
+		return oneByOne
 	}
-	return nil
+	return exp
 }
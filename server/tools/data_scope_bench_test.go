@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const fixtureTenantID = "000000"
+
+// fixtureCollectionDef is the part of a collections.json entry used by the fixture.
+type fixtureCollectionDef struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Fields json.RawMessage `json:"fields"`
+}
+
+// newDataScopeFixture creates a migrated sqlite app in a temp dir with the data scope related
+// collections of ../collections.json. Only the fields are imported (rules and indexes are not needed).
+func newDataScopeFixture(tb testing.TB) core.App {
+	tb.Helper()
+
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: tb.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = app.ResetBootstrapState() })
+	if err := app.RunAllMigrations(); err != nil {
+		tb.Fatal(err)
+	}
+
+	raw, err := os.ReadFile("../collections.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defs := []fixtureCollectionDef{}
+	if err := json.Unmarshal(raw, &defs); err != nil {
+		tb.Fatal(err)
+	}
+
+	wanted := []string{"users", "role", "dept", "post", "notice", "user_role", "role_dept", "user_post"}
+	for _, name := range wanted {
+		i := slices.IndexFunc(defs, func(d fixtureCollectionDef) bool { return d.Name == name })
+		if i < 0 {
+			tb.Fatalf("collection %s not found in collections.json", name)
+		}
+
+		fields := core.NewFieldsList()
+		if err := json.Unmarshal(defs[i].Fields, &fields); err != nil {
+			tb.Fatal(err)
+		}
+
+		coll, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			coll = core.NewBaseCollection(name, defs[i].ID)
+			coll.Fields = fields
+		} else {
+			// existing auth collection: keep its system fields and add the missing ones
+			for _, f := range fields {
+				if coll.Fields.GetByName(f.GetName()) == nil {
+					coll.Fields.Add(f)
+				}
+			}
+		}
+		if err := app.SaveNoValidate(coll); err != nil {
+			tb.Fatalf("save collection %s: %v", name, err)
+		}
+	}
+	return app
+}
+
+// insertFixtureRows inserts raw rows into a table, bypassing record hooks and validation.
+func insertFixtureRows(tb testing.TB, app core.App, table string, rows ...dbx.Params) {
+	tb.Helper()
+	for _, row := range rows {
+		if _, err := app.DB().Insert(table, row).Execute(); err != nil {
+			tb.Fatalf("insert %s %v: %v", table, row, err)
+		}
+	}
+}
+
+// fixtureRequest returns a request event authenticated as the given users record.
+func fixtureRequest(tb testing.TB, app core.App, userID string) *core.RequestEvent {
+	tb.Helper()
+	user, err := app.FindRecordById("users", userID)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	e := &core.RequestEvent{App: app}
+	e.Request = httptest.NewRequest("GET", "/", nil)
+	e.Auth = user
+	return e
+}
+
+// BenchmarkDataScopeDeptSubtree measures department scope "4" on 10k departments and 100k rows.
+// Departments form a 10-ary tree rooted at 100; the user sits in 101, whose subtree holds ~1/10 of them.
+func BenchmarkDataScopeDeptSubtree(b *testing.B) {
+	const (
+		deptCount = 10_000
+		rowCount  = 100_000
+	)
+
+	app := newDataScopeFixture(b)
+	err := app.RunInTransaction(func(txApp core.App) error {
+		for i := range deptCount {
+			parent := "0"
+			if i > 0 {
+				parent = strconv.Itoa(100 + (i-1)/10)
+			}
+			insertFixtureRows(b, txApp, "dept", dbx.Params{
+				"id": strconv.Itoa(100 + i), "tenant_id": fixtureTenantID, "parent_id": parent,
+				"dept_name": fmt.Sprintf("dept-%d", i), "status": "0", "del_flag": "0",
+			})
+		}
+		for i := range rowCount {
+			insertFixtureRows(b, txApp, "post", dbx.Params{
+				"id": fmt.Sprintf("p%014d", i), "tenant_id": fixtureTenantID,
+				"create_dept": strconv.Itoa(100 + i%deptCount), "create_by": "bench_user_0001",
+				"post_code": fmt.Sprintf("code-%d", i), "post_name": fmt.Sprintf("post-%d", i),
+				"status": "0", "del_flag": "0",
+			})
+		}
+		insertFixtureRows(b, txApp, "users", dbx.Params{
+			"id": "bench_user_0001", "tenant_id": fixtureTenantID, "dept_id": 101,
+			"email": "bench@example.com", "user_name": "bench", "tokenKey": "bench", "password": "x",
+		})
+		insertFixtureRows(b, txApp, "role", dbx.Params{
+			"id": "bench_role_0001", "tenant_id": fixtureTenantID, "role_key": "bench",
+			"role_name": "bench", "data_scope": "4", "status": "0", "del_flag": "0",
+		})
+		insertFixtureRows(b, txApp, "user_role", dbx.Params{
+			"id": "bench_urole_001", "user": "bench_user_0001", "role": "bench_role_0001",
+		})
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	coll, err := app.FindCollectionByNameOrId("post")
+	if err != nil {
+		b.Fatal(err)
+	}
+	e := fixtureRequest(b, app, "bench_user_0001")
+
+	b.Run("subtree", func(b *testing.B) {
+		sql, params := deptSubtreeQuery("101")
+		for b.Loop() {
+			n := 0
+			if err := app.DB().NewQuery("SELECT count(*) FROM (" + sql + ")").Bind(params).Row(&n); err != nil {
+				b.Fatal(err)
+			}
+			if n != 1111 {
+				b.Fatalf("expected 1111 departments in subtree, got %d", n)
+			}
+		}
+	})
+
+	page := func(b *testing.B, exp dbx.Expression) {
+		rows := []dbx.NullStringMap{}
+		err := app.DB().Select("id").From("post").Where(exp).OrderBy("id").Limit(30).All(&rows)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(rows) != 30 {
+			b.Fatalf("expected a full page, got %d rows", len(rows))
+		}
+	}
+
+	b.Run("expression", func(b *testing.B) {
+		for b.Loop() {
+			InvalidateDataScopeCache()
+			if _, err := DataScopeExpression(e, coll, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("page", func(b *testing.B) {
+		for b.Loop() {
+			InvalidateDataScopeCache()
+			exp, err := DataScopeExpression(e, coll, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+			page(b, exp)
+		}
+	})
+
+	b.Run("page_cached", func(b *testing.B) {
+		for b.Loop() {
+			exp, err := DataScopeExpression(e, coll, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+			page(b, exp)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+// dataScopeCacheTTL bounds how long a computed data scope is reused even without invalidation.
+const dataScopeCacheTTL = 5 * time.Minute
+
+// dataScopeGeneration is bumped on every invalidation; it is part of the cache key,
+// so stale entries are simply never read again and expire on their own.
+var dataScopeGeneration atomic.Int64
+
+type dataScopeCacheEntry struct {
+	exp dbx.Expression
+	err error
+}
+
+func dataScopeCacheKey(userID, tenantID, collectionName string, qualified bool) string {
+	return fmt.Sprintf("data_scope:%d:%s:%s:%s:%t", dataScopeGeneration.Load(), userID, tenantID, collectionName, qualified)
+}
+
+// InvalidateDataScopeCache drops all cached data scope expressions.
+// Call it whenever roles, role/user links, departments or users change.
+func InvalidateDataScopeCache() {
+	dataScopeGeneration.Add(1)
+}
@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// Default field names used by data scope when a collection has no mapping.
+const (
+	defaultTenantField = "tenant_id"
+	defaultDeptField   = "create_dept"
+	defaultOwnerField  = "create_by"
+)
+
+// DataScopeField describes a single column used by data scope filtering.
+// Multi is true for multi-value relation/select fields (stored as JSON arrays),
+// in which case a record matches when any of its values matches.
+type DataScopeField struct {
+	Name  string
+	Multi bool
+	// Table optionally qualifies the column in SQL expressions (see DataScopeFields.WithTable).
+	Table string
+}
+
+// DataScopeFields holds the tenant, department and owner columns of a collection.
+// A zero-value field means the collection is not scoped by that dimension.
+type DataScopeFields struct {
+	Tenant DataScopeField
+	Dept   DataScopeField
+	Owner  DataScopeField
+}
+
+var (
+	dataScopeFieldsOnce sync.Once
+	dataScopeFieldsCfg  map[string]map[string]string
+)
+
+// GetDataScopeFields resolves the data scope columns of a collection, applying
+// config/data_scope_fields.yml overrides on top of tenant_id/create_dept/create_by.
+// Fields that don't exist in the collection are returned as zero values.
+func GetDataScopeFields(collection *core.Collection) DataScopeFields {
+	if collection == nil {
+		return DataScopeFields{}
+	}
+
+	dataScopeFieldsOnce.Do(func() {
+		dataScopeFieldsCfg = loadDataScopeFieldsConfig()
+	})
+
+	names := map[string]string{
+		"tenant": defaultTenantField,
+		"dept":   defaultDeptField,
+		"owner":  defaultOwnerField,
+	}
+	for k, v := range dataScopeFieldsCfg[collection.Name] {
+		if _, ok := names[k]; ok {
+			names[k] = v
+		}
+	}
+
+	return DataScopeFields{
+		Tenant: resolveDataScopeField(collection, names["tenant"]),
+		Dept:   resolveDataScopeField(collection, names["dept"]),
+		Owner:  resolveDataScopeField(collection, names["owner"]),
+	}
+}
+
+// resolveDataScopeField looks up the named field in the collection and detects multi-value fields.
+func resolveDataScopeField(collection *core.Collection, name string) DataScopeField {
+	if name == "" {
+		return DataScopeField{}
+	}
+	f := collection.Fields.GetByName(name)
+	if f == nil {
+		return DataScopeField{}
+	}
+	multi := false
+	if mv, ok := f.(core.MultiValuer); ok {
+		multi = mv.IsMultiple()
+	}
+	return DataScopeField{Name: f.GetName(), Multi: multi}
+}
+
+// WithTable returns a copy of fs whose SQL expressions qualify columns with table.
+func (fs DataScopeFields) WithTable(table string) DataScopeFields {
+	for _, f := range []*DataScopeField{&fs.Tenant, &fs.Dept, &fs.Owner} {
+		if !f.IsZero() {
+			f.Table = table
+		}
+	}
+	return fs
+}
+
+// column returns the (optionally table qualified) column name for SQL expressions.
+func (f DataScopeField) column() string {
+	if f.Table != "" {
+		return f.Table + "." + f.Name
+	}
+	return f.Name
+}
+
+// IsZero reports whether the field is unset (the collection isn't scoped by it).
+func (f DataScopeField) IsZero() bool {
+	return f.Name == ""
+}
+
+// FilterEq returns a PocketBase filter clause matching records whose field equals id
+// (or, for multi-value fields, contains id).
+func (f DataScopeField) FilterEq(id string) string {
+	if f.Multi {
+		return fmt.Sprintf("%s?=\"%s\"", f.Name, id)
+	}
+	return fmt.Sprintf("%s=\"%s\"", f.Name, id)
+}
+
+// ExpressionIn returns a dbx expression matching records whose field is one of ids
+// (or, for multi-value fields, contains at least one of ids).
+func (f DataScopeField) ExpressionIn(ids ...string) dbx.Expression {
+	if f.IsZero() || len(ids) == 0 {
+		return nil
+	}
+	if !f.Multi {
+		if len(ids) == 1 {
+			return dbx.HashExp{f.column(): ids[0]}
+		}
+		vals := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			vals = append(vals, id)
+		}
+		return dbx.In(f.column(), vals...)
+	}
+
+	params := dbx.Params{}
+	placeholders := make([]string, 0, len(ids))
+	for _, id := range ids {
+		key := dataScopeParamName()
+		params[key] = id
+		placeholders = append(placeholders, "{:"+key+"}")
+	}
+	return f.ExpressionInQuery(strings.Join(placeholders, ", "), params)
+}
+
+// ExpressionInQuery returns a dbx expression matching records whose field value is
+// produced by the given SQL (a SELECT or a list of placeholders) with its params.
+func (f DataScopeField) ExpressionInQuery(query string, params dbx.Params) dbx.Expression {
+	if f.IsZero() {
+		return nil
+	}
+	if !f.Multi {
+		return dbx.NewExp(fmt.Sprintf("[[%s]] IN (%s)", f.column(), query), params)
+	}
+	// multi-value fields are stored as JSON arrays; match any element
+	return dbx.NewExp(fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM json_each(CASE WHEN json_valid([[%s]]) THEN [[%s]] ELSE '[]' END) WHERE json_each.value IN (%s))",
+		f.column(), f.column(), query,
+	), params)
+}
+
+// dataScopeParamName returns a random placeholder name, so that expressions
+// built separately can be combined in a single query without param collisions.
+func dataScopeParamName() string {
+	return "ds" + security.PseudorandomString(10)
+}
+
+// Assign writes id into the field of rec, wrapping it in a slice for multi-value fields.
+func (f DataScopeField) Assign(rec *core.Record, id string) {
+	if f.IsZero() || rec == nil {
+		return
+	}
+	if f.Multi {
+		rec.Set(f.Name, []string{id})
+		return
+	}
+	rec.Set(f.Name, id)
+}
+
+// AssignIfEmpty behaves like Assign but keeps any value already present on rec.
+func (f DataScopeField) AssignIfEmpty(rec *core.Record, id string) {
+	if f.IsZero() || rec == nil {
+		return
+	}
+	if f.Multi {
+		if len(rec.GetStringSlice(f.Name)) > 0 {
+			return
+		}
+	} else if rec.GetString(f.Name) != "" {
+		return
+	}
+	f.Assign(rec, id)
+}
+
+// loadDataScopeFieldsConfig loads per-collection field overrides from config/data_scope_fields.yml.
+// Returns collection -> {tenant|dept|owner -> field name}; an empty name disables that dimension.
+// If the file is missing or parsing fails, returns an empty map (defaults apply).
+func loadDataScopeFieldsConfig() map[string]map[string]string {
+	cfg := make(map[string]map[string]string)
+
+	cfgPath := filepath.Join("config", "data_scope_fields.yml")
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		return cfg
+	}
+
+	// Simple indentation-based parsing:
+	// collections:
+	//   biz_order:
+	//     dept: dept_id
+	lines := strings.Split(string(data), "\n")
+	inSection := false
+	collIndent := -1
+	current := ""
+	for _, ln := range lines {
+		if i := strings.Index(ln, "#"); i >= 0 {
+			ln = ln[:i]
+		}
+		t := strings.TrimSpace(ln)
+		if t == "" {
+			continue
+		}
+		indent := len(ln) - len(strings.TrimLeft(ln, " \t"))
+
+		if indent == 0 {
+			inSection = t == "collections:"
+			collIndent = -1
+			current = ""
+			continue
+		}
+		if !inSection {
+			continue
+		}
+
+		if collIndent < 0 {
+			collIndent = indent
+		}
+
+		if indent <= collIndent {
+			current = strings.Trim(strings.TrimSuffix(t, ":"), "\"'")
+			if current != "" {
+				if _, ok := cfg[current]; !ok {
+					cfg[current] = map[string]string{}
+				}
+			}
+			continue
+		}
+
+		if current == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(t, ":")
+		if !ok {
+			continue
+		}
+		k = strings.TrimSpace(k)
+		v = strings.Trim(strings.TrimSpace(v), "\"'")
+		cfg[current][k] = v
+	}
+
+	return cfg
+}
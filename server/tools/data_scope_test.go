@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// TestBuildDataScopeExpressionCombinations checks that the leader team (7) and post (8)
+// scopes are OR-combined with the other roles of the user.
+//
+// Fixture (tenant 000000, one notice per user created by that user in the user's dept):
+//
+//	100 ─┬─ 101 (u1) ── 104 (u5)
+//	     ├─ 102 (u2, leader u1) ── 103 (u3)
+//	     └─ 106 (u4, shares post p1 with u1)
+//
+// n9 belongs to another tenant and must never be visible.
+func TestBuildDataScopeExpressionCombinations(t *testing.T) {
+	app := newDataScopeFixture(t)
+
+	depts := []struct{ id, parent, leader string }{
+		{"100", "0", ""}, {"101", "100", ""}, {"102", "100", "u1"},
+		{"103", "102", ""}, {"104", "101", ""}, {"106", "100", ""},
+	}
+	for _, d := range depts {
+		insertFixtureRows(t, app, "dept", dbx.Params{
+			"id": d.id, "tenant_id": fixtureTenantID, "parent_id": d.parent, "leader": d.leader,
+			"dept_name": "dept-" + d.id, "status": "0", "del_flag": "0",
+		})
+	}
+
+	users := []struct {
+		id, tenant string
+		dept       int
+	}{
+		{"u1", fixtureTenantID, 101}, {"u2", fixtureTenantID, 102}, {"u3", fixtureTenantID, 103},
+		{"u4", fixtureTenantID, 106}, {"u5", fixtureTenantID, 104}, {"u9", "000001", 101},
+	}
+	for _, u := range users {
+		insertFixtureRows(t, app, "users", dbx.Params{
+			"id": u.id, "tenant_id": u.tenant, "dept_id": u.dept,
+			"email": u.id + "@example.com", "user_name": u.id, "tokenKey": u.id, "password": "x",
+		})
+		insertFixtureRows(t, app, "notice", dbx.Params{
+			"id": "n" + strings.TrimPrefix(u.id, "u"), "tenant_id": u.tenant,
+			"create_by": u.id, "create_dept": fmt.Sprint(u.dept), "notice_title": u.id,
+		})
+	}
+
+	insertFixtureRows(t, app, "post",
+		dbx.Params{"id": "p1", "tenant_id": fixtureTenantID, "post_code": "p1", "post_name": "p1"},
+	)
+	insertFixtureRows(t, app, "user_post",
+		dbx.Params{"id": "up1", "user": "u1", "post": "p1"},
+		dbx.Params{"id": "up4", "user": "u4", "post": "p1"},
+	)
+	for _, scope := range []string{"1", "4", "5", "7", "8"} {
+		insertFixtureRows(t, app, "role", dbx.Params{
+			"id": "r" + scope, "tenant_id": fixtureTenantID, "role_key": "r" + scope,
+			"role_name": "r" + scope, "data_scope": scope, "status": "0", "del_flag": "0",
+		})
+	}
+
+	coll, err := app.FindCollectionByNameOrId("notice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := fixtureRequest(t, app, "u1")
+
+	all := []string{"n1", "n2", "n3", "n4", "n5"}
+	scenarios := []struct {
+		scopes   []string
+		expected []string
+	}{
+		{[]string{"7"}, []string{"n1", "n2", "n3"}},
+		{[]string{"8"}, []string{"n1", "n4"}},
+		{[]string{"7", "8"}, []string{"n1", "n2", "n3", "n4"}},
+		{[]string{"7", "1"}, all},
+		{[]string{"8", "1"}, all},
+		{[]string{"7", "5"}, []string{"n1", "n2", "n3"}},
+		{[]string{"8", "5"}, []string{"n1", "n4"}},
+		{[]string{"7", "4"}, []string{"n1", "n2", "n3", "n5"}},
+		{[]string{"8", "4"}, []string{"n1", "n4", "n5"}},
+		{[]string{"7", "8", "4"}, []string{"n1", "n2", "n3", "n4", "n5"}},
+	}
+
+	for _, s := range scenarios {
+		t.Run(strings.Join(s.scopes, "+"), func(t *testing.T) {
+			if _, err := app.DB().Delete("user_role", nil).Execute(); err != nil {
+				t.Fatal(err)
+			}
+			for _, scope := range s.scopes {
+				insertFixtureRows(t, app, "user_role", dbx.Params{
+					"id": "ur" + scope, "user": "u1", "role": "r" + scope,
+				})
+			}
+
+			exp, err := buildDataScopeExpression(e, coll, fixtureTenantID, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			visible := visibleFixtureIDs(t, app, "notice", exp)
+			if !slices.Equal(visible, s.expected) {
+				t.Fatalf("expected %v, got %v", s.expected, visible)
+			}
+		})
+	}
+}
+
+func visibleFixtureIDs(t *testing.T, app core.App, table string, exp dbx.Expression) []string {
+	t.Helper()
+	ids := []string{}
+	q := app.DB().Select("id").From(table).OrderBy("id")
+	if exp != nil {
+		q.Where(exp)
+	}
+	if err := q.Column(&ids); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
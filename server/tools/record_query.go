@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+// superuserOnlyFilterFields mirrors PocketBase's list API restriction on client filters/sorts.
+var superuserOnlyFilterFields = []string{"@collection.", "@request."}
+
+// CheckClientFilterFields rejects client filter/sort expressions referencing fields
+// that only superusers may use (@collection.*, @request.*), like the records list API.
+func CheckClientFilterFields(e *core.RequestEvent, values ...string) error {
+	if e.HasSuperuserAuth() {
+		return nil
+	}
+	for _, v := range values {
+		for _, field := range superuserOnlyFilterFields {
+			if v != "" && strings.Contains(v, field) {
+				return apis.NewForbiddenError("Only superusers can filter by "+field, nil)
+			}
+		}
+	}
+	return nil
+}
+
+// ScopedRecordQuery returns a records query restricted exactly like the records list API
+// for the current request: the collection list rule plus the caller's tenant and data scope
+// (skipped for collections in config/data_scope_whitelist.yml).
+//
+// The returned resolver is bound to the request; use it to build client filters/sorts
+// and call resolver.UpdateQuery(query) before executing.
+func ScopedRecordQuery(e *core.RequestEvent, collection *core.Collection) (*dbx.SelectQuery, *core.RecordFieldResolver, error) {
+	requestInfo, err := e.RequestInfo()
+	if err != nil {
+		return nil, nil, apis.NewBadRequestError("", err)
+	}
+
+	query := e.App.RecordQuery(collection)
+
+	if requestInfo.HasSuperuserAuth() {
+		return query, core.NewRecordFieldResolver(e.App, collection, requestInfo, true), nil
+	}
+
+	if collection.ListRule == nil {
+		return nil, nil, apis.NewForbiddenError("Only superusers can perform this action.", nil)
+	}
+
+	if err := CheckClientFilterFields(e, requestInfo.Query[search.FilterQueryParam], requestInfo.Query[search.SortQueryParam]); err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := loadDataScopeWhitelist()[collection.Name]; !ok {
+		scope, err := DataScopeExpression(e, collection, true)
+		if err != nil {
+			return nil, nil, apis.NewForbiddenError(err.Error(), nil)
+		}
+		if scope != nil {
+			query.AndWhere(scope)
+		}
+	}
+
+	resolver := core.NewRecordFieldResolver(e.App, collection, requestInfo, true)
+
+	if *collection.ListRule != "" {
+		expr, err := search.FilterData(*collection.ListRule).BuildExpr(resolver)
+		if err != nil {
+			return nil, nil, err
+		}
+		query.AndWhere(expr)
+	}
+
+	// hidden fields are searchable only by superusers
+	resolver.SetAllowHiddenFields(false)
+
+	return query, resolver, nil
+}
@@ -1,40 +1,237 @@
 package tools
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/pocketbase/pocketbase/core"
 )
 
-// SetUserTenant 设置当前用户临时租户ID
-func SetUserTenant(e *core.RequestEvent, tenantID string) {
+// TenantHeader 超级管理员单次请求指定租户的请求头
+const TenantHeader = "X-Tenant-Id"
+
+// tenantSwitchKeyPrefix 租户切换会话在 app.Store() 中的键前缀
+const tenantSwitchKeyPrefix = "tenant_switch_"
+
+// TenantSwitch 租户切换会话：登录令牌在有效期内以 TenantID 作为当前租户
+type TenantSwitch struct {
+	ID        string    `json:"id"` // 登录令牌的摘要（不保存原始令牌）
+	UserID    string    `json:"userId"`
+	UserName  string    `json:"userName"`
+	TenantID  string    `json:"tenantId"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (s *TenantSwitch) expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)
+}
+
+// tenantSwitchID 由登录令牌计算切换会话ID
+func tenantSwitchID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetUserTenant 为当前登录令牌创建租户切换会话，ttl 后自动失效
+func SetUserTenant(e *core.RequestEvent, tenantID string, ttl time.Duration) *TenantSwitch {
 	if e.Auth == nil {
-		return
+		return nil
 	}
 
-	token := GetAuthTokenFromRequest(e)
-	e.App.Store().Set("temp_tenant_id_for_user_"+token, tenantID)
+	now := time.Now()
+	s := &TenantSwitch{
+		ID:        tenantSwitchID(GetAuthTokenFromRequest(e)),
+		UserID:    e.Auth.Id,
+		UserName:  firstNonEmpty(e.Auth.GetString("user_name"), e.Auth.Email()),
+		TenantID:  tenantID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	e.App.Store().Set(tenantSwitchKeyPrefix+s.ID, s)
+	return s
 }
 
-// ClearUserTenant 清理当前用户临时租户ID
-func ClearUserTenant(e *core.RequestEvent) {
+// ClearUserTenant 清理当前登录令牌的租户切换会话，返回被清理的会话（没有时为 nil）
+func ClearUserTenant(e *core.RequestEvent) *TenantSwitch {
 	if e.Auth == nil {
-		return
+		return nil
+	}
+
+	s := GetTenantSwitch(e)
+	e.App.Store().Remove(tenantSwitchKeyPrefix + tenantSwitchID(GetAuthTokenFromRequest(e)))
+	return s
+}
+
+// GetTenantSwitch 获取当前登录令牌未过期的租户切换会话
+func GetTenantSwitch(e *core.RequestEvent) *TenantSwitch {
+	if e.Auth == nil {
+		return nil
+	}
+
+	key := tenantSwitchKeyPrefix + tenantSwitchID(GetAuthTokenFromRequest(e))
+	s, ok := e.App.Store().Get(key).(*TenantSwitch)
+	if !ok {
+		return nil
+	}
+	if s.expired(time.Now()) || s.UserID != e.Auth.Id {
+		e.App.Store().Remove(key)
+		return nil
+	}
+	return s
+}
+
+// ListTenantSwitches 列出所有未过期的租户切换会话（顺带清理已过期的会话）
+func ListTenantSwitches(app core.App) []*TenantSwitch {
+	now := time.Now()
+	list := []*TenantSwitch{}
+	for key, v := range app.Store().GetAll() {
+		s, ok := v.(*TenantSwitch)
+		if !ok || !strings.HasPrefix(key, tenantSwitchKeyPrefix) {
+			continue
+		}
+		if s.expired(now) {
+			app.Store().Remove(key)
+			continue
+		}
+		list = append(list, s)
 	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
 
-	token := GetAuthTokenFromRequest(e)
-	e.App.Store().Remove("temp_tenant_id_for_user_" + token)
+// RevokeTenantSwitch 撤销指定的租户切换会话，返回被撤销的会话（不存在时为 nil）
+func RevokeTenantSwitch(app core.App, id string) *TenantSwitch {
+	key := tenantSwitchKeyPrefix + id
+	s, _ := app.Store().Get(key).(*TenantSwitch)
+	app.Store().Remove(key)
+	return s
 }
 
-// GetUserTenant 获取当前用户的租户ID
+// revokeUserTenantSwitches 撤销用户的所有租户切换会话
+func revokeUserTenantSwitches(app core.App, userID string) {
+	for _, s := range ListTenantSwitches(app) {
+		if s.UserID == userID {
+			app.Store().Remove(tenantSwitchKeyPrefix + s.ID)
+		}
+	}
+}
+
+// requestTenantKey 请求上下文中通过 X-Tenant-Id 指定的租户ID
+const requestTenantKey = "requestTenantId"
+
+// SetRequestTenant 设置仅对当前请求生效的租户（X-Tenant-Id），调用方负责校验权限
+func SetRequestTenant(e *core.RequestEvent, tenantID string) {
+	e.Set(requestTenantKey, tenantID)
+}
+
+// GetUserTenant 获取当前用户的租户ID，优先级：X-Tenant-Id 请求头 > 租户切换会话 > 用户所属租户
 func GetUserTenant(e *core.RequestEvent) string {
 	if e.Auth == nil {
 		return ""
 	}
-	token := GetAuthTokenFromRequest(e)
-	temp := e.App.Store().Get("temp_tenant_id_for_user_" + token)
-	if tempStr, ok := temp.(string); ok && strings.TrimSpace(tempStr) != "" {
-		return tempStr
+	if tenantID, _ := e.Get(requestTenantKey).(string); tenantID != "" {
+		return tenantID
+	}
+	if s := GetTenantSwitch(e); s != nil {
+		return s.TenantID
 	}
 	return e.Auth.GetString("tenant_id")
 }
+
+// IsTenantSwitched 当前请求是否在非本人所属的租户下操作（切换会话或 X-Tenant-Id）
+func IsTenantSwitched(e *core.RequestEvent) bool {
+	if e.Auth == nil {
+		return false
+	}
+	return GetUserTenant(e) != e.Auth.GetString("tenant_id")
+}
+
+// domainTenantKey 请求上下文中按域名解析出的租户ID
+const domainTenantKey = "domainTenantId"
+
+// SetDomainTenant 设置当前请求按域名解析出的租户ID
+func SetDomainTenant(e *core.RequestEvent, tenantID string) {
+	e.Set(domainTenantKey, tenantID)
+}
+
+// GetDomainTenant 获取当前请求按域名解析出的租户ID（未匹配时为空）
+func GetDomainTenant(e *core.RequestEvent) string {
+	tenantID, _ := e.Get(domainTenantKey).(string)
+	return tenantID
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// TenantPackageMenus 返回租户套餐允许分配的菜单ID集合。
+// 默认租户、未绑定套餐或套餐不存在时 restricted 为 false，表示不限制。
+func TenantPackageMenus(app core.App, tenantID string) (menus map[string]struct{}, restricted bool) {
+	pkg := tenantPackage(app, tenantID)
+	if pkg == nil {
+		return nil, false
+	}
+
+	menus = map[string]struct{}{}
+	for _, id := range pkg.GetStringSlice("menu_ids") {
+		menus[id] = struct{}{}
+	}
+	return menus, true
+}
+
+// tenantPackageLimitGeneration 套餐限制缓存的版本号，递增即令所有缓存失效
+var tenantPackageLimitGeneration atomic.Int64
+
+// ClearTenantPackageLimits 使租户套餐限制缓存失效（套餐或租户变更后调用）
+func ClearTenantPackageLimits() {
+	tenantPackageLimitGeneration.Add(1)
+}
+
+// TenantPackageLimit 返回租户套餐的数值限制（如 api_call_limit），0 表示不限制。
+// 结果缓存一分钟。
+func TenantPackageLimit(app core.App, tenantID, field string) int64 {
+	key := fmt.Sprintf("tenant_package_limit:%d:%s:%s", tenantPackageLimitGeneration.Load(), tenantID, field)
+	if v, ok := CacheGetValue(key); ok {
+		if limit, ok := v.(int64); ok {
+			return limit
+		}
+	}
+
+	var limit int64
+	if pkg := tenantPackage(app, tenantID); pkg != nil {
+		limit = int64(pkg.GetInt(field))
+	}
+	CacheSetValue(key, limit, time.Minute)
+	return limit
+}
+
+// tenantPackage 返回租户绑定的套餐；默认租户、未绑定套餐（package_id 为 0）或套餐不存在时返回 nil
+func tenantPackage(app core.App, tenantID string) *core.Record {
+	if tenantID == "" || tenantID == "000000" {
+		return nil
+	}
+	tenant, err := app.FindRecordById("tenant", tenantID)
+	if err != nil {
+		return nil
+	}
+	pkgID := tenant.GetString("package_id")
+	if pkgID == "" || pkgID == "0" {
+		return nil
+	}
+	pkg, err := app.FindRecordById("tenant_package", pkgID)
+	if err != nil {
+		return nil
+	}
+	return pkg
+}
@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// 租户 API 调用计数：请求时在内存中累加（RBAC 中间件），
+// 由租户模块定期写入 tenant_usage 月度汇总（见 api/tenant/usage.go）。
+
+// apiCallCounter 单个租户某月的调用计数
+type apiCallCounter struct {
+	tenantID string
+	month    string
+	total    int64 // 本月累计（含未写入的部分）
+	pending  int64 // 尚未写入 tenant_usage 的部分
+}
+
+var (
+	apiCallsMu sync.Mutex
+	apiCalls   = map[string]*apiCallCounter{}
+)
+
+// PendingAPICalls 待写入的调用次数
+type PendingAPICalls struct {
+	TenantID string
+	Month    string
+	Calls    int64
+}
+
+// UsageMonth 用量统计的月份（本地时间，如 2026-10）
+func UsageMonth(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// IncTenantAPICalls 记一次租户 API 调用，返回本月累计调用次数
+func IncTenantAPICalls(app core.App, tenantID string) int64 {
+	c := apiCallCounterFor(app, tenantID, UsageMonth(time.Now()))
+
+	apiCallsMu.Lock()
+	defer apiCallsMu.Unlock()
+	c.total++
+	c.pending++
+	return c.total
+}
+
+// TenantAPICalls 返回租户本月累计调用次数
+func TenantAPICalls(app core.App, tenantID string) int64 {
+	c := apiCallCounterFor(app, tenantID, UsageMonth(time.Now()))
+
+	apiCallsMu.Lock()
+	defer apiCallsMu.Unlock()
+	return c.total
+}
+
+// apiCallCounterFor 获取计数器，首次使用时从 tenant_usage 读取已写入的次数
+func apiCallCounterFor(app core.App, tenantID, month string) *apiCallCounter {
+	key := tenantID + "|" + month
+
+	apiCallsMu.Lock()
+	c, ok := apiCalls[key]
+	apiCallsMu.Unlock()
+	if ok {
+		return c
+	}
+
+	var stored int64
+	_ = app.DB().Select("api_calls").From("tenant_usage").
+		Where(dbx.HashExp{"tenant_id": tenantID, "month": month}).
+		Row(&stored)
+
+	apiCallsMu.Lock()
+	defer apiCallsMu.Unlock()
+	if c, ok := apiCalls[key]; ok {
+		return c
+	}
+	c = &apiCallCounter{tenantID: tenantID, month: month, total: stored}
+	apiCalls[key] = c
+	return c
+}
+
+// TakePendingAPICalls 取出所有待写入的调用次数并清零；往月的计数器随之释放
+func TakePendingAPICalls() []PendingAPICalls {
+	month := UsageMonth(time.Now())
+
+	apiCallsMu.Lock()
+	defer apiCallsMu.Unlock()
+
+	out := []PendingAPICalls{}
+	for key, c := range apiCalls {
+		if c.pending > 0 {
+			out = append(out, PendingAPICalls{TenantID: c.tenantID, Month: c.month, Calls: c.pending})
+			c.pending = 0
+		}
+		if c.month != month {
+			delete(apiCalls, key)
+		}
+	}
+	return out
+}
+
+// RestorePendingAPICalls 写入失败时放回待写入的调用次数
+func RestorePendingAPICalls(p PendingAPICalls) {
+	apiCallsMu.Lock()
+	defer apiCallsMu.Unlock()
+
+	key := p.TenantID + "|" + p.Month
+	c, ok := apiCalls[key]
+	if !ok {
+		c = &apiCallCounter{tenantID: p.TenantID, month: p.Month}
+		apiCalls[key] = c
+	}
+	c.pending += p.Calls
+}